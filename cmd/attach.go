@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"spm/pkg/client"
+	"spm/pkg/config"
+	"spm/pkg/supervisor"
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach name",
+	Short: "Attach the terminal to a process running in a pseudo-terminal",
+	Long: `Connect the terminal to a process started with tty: true in Procfile.options
+(or spm run --tty). Keyboard input goes to the process and window size changes are
+passed on; the output is still written to the process's log file.
+
+Press the detach keys (default ctrl-p,ctrl-q) to detach and leave the process running.`,
+	Args: cobra.ExactArgs(1),
+	Run:  execAttachCmd,
+}
+
+var attachDetachKeys string
+
+func init() {
+	attachCmd.Flags().StringVar(&attachDetachKeys, "detach-keys", "ctrl-p,ctrl-q", "Key sequence to detach, e.g. ctrl-a,d")
+
+	setupCommandPreRun(attachCmd, requireDaemonRunning)
+	rootCmd.AddCommand(attachCmd)
+}
+
+func execAttachCmd(cmd *cobra.Command, args []string) {
+	detach, err := parseDetachKeys(attachDetachKeys)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	if _, err := unix.IoctlGetTermios(int(os.Stdin.Fd()), unix.TCGETS); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: spm attach requires an interactive terminal")
+		os.Exit(1)
+	}
+
+	c := client.New(client.WithProject(config.WorkDirFlag, config.ProcfileFlag), client.WithTimeout(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	input := make(chan *supervisor.AttachMsg, 16)
+	send := func(msg *supervisor.AttachMsg) {
+		select {
+		case input <- msg:
+		case <-ctx.Done():
+		}
+	}
+
+	// 先发送当前的窗口大小，之后每次窗口变化时再发送
+	input <- terminalSize()
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for range winch {
+			send(terminalSize())
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Attaching to %s, detach with %s\n", args[0], attachDetachKeys)
+
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	go func() {
+		d := &detachDetector{keys: detach}
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				cancel()
+				return
+			}
+
+			data, detached := d.feed(buf[:n])
+			if len(data) > 0 {
+				send(&supervisor.AttachMsg{Data: data})
+			}
+			if detached {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	err = c.Attach(ctx, args[0], input, func(data []byte) error {
+		_, err := os.Stdout.Write(data)
+		return err
+	})
+	restore()
+
+	switch {
+	case err == nil:
+		fmt.Fprintf(os.Stderr, "\nProcess %s exited\n", args[0])
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintf(os.Stderr, "\nDetached from %s\n", args[0])
+	default:
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+// terminalSize 返回当前终端的窗口大小
+func terminalSize() *supervisor.AttachMsg {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		ws, err = unix.IoctlGetWinsize(int(os.Stdin.Fd()), unix.TIOCGWINSZ)
+	}
+	if err != nil || ws.Row == 0 || ws.Col == 0 {
+		return &supervisor.AttachMsg{Rows: 24, Cols: 80}
+	}
+
+	return &supervisor.AttachMsg{Rows: ws.Row, Cols: ws.Col}
+}
+
+// makeRaw 把终端切换到原始模式，按键原样发送给进程，由进程的伪终端负责回显和信号
+func makeRaw(f *os.File) (func(), error) {
+	fd := int(f.Fd())
+
+	old, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *old
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = unix.IoctlSetTermios(fd, unix.TCSETS, old)
+	}, nil
+}
+
+// parseDetachKeys 解析 --detach-keys，例如 "ctrl-p,ctrl-q"、"ctrl-a,d"
+func parseDetachKeys(s string) ([]byte, error) {
+	keys := make([]byte, 0)
+
+	for _, key := range strings.Split(s, ",") {
+		key = strings.TrimSpace(key)
+		lower := strings.ToLower(key)
+
+		switch {
+		case strings.HasPrefix(lower, "ctrl-") && len(lower) == 6:
+			c := lower[5]
+			switch {
+			case c >= 'a' && c <= 'z':
+				keys = append(keys, c-'a'+1)
+			case c == '@':
+				keys = append(keys, 0)
+			case c >= '[' && c <= '_':
+				keys = append(keys, c-'['+0x1b)
+			default:
+				return nil, fmt.Errorf("invalid detach key %q", key)
+			}
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		default:
+			return nil, fmt.Errorf("invalid detach key %q, expected a single character or ctrl-<key>", key)
+		}
+	}
+
+	return keys, nil
+}
+
+// detachDetector 在输入中查找 detach 按键序列
+type detachDetector struct {
+	keys    []byte
+	matched int // 已经匹配的按键数
+}
+
+// feed 返回需要发送给进程的输入，以及是否输入了完整的 detach 序列
+//
+// 部分匹配的按键先保留，后续按键不匹配时再原样发送，因此只输入 ctrl-p 时进程也能收到
+func (d *detachDetector) feed(b []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(b))
+
+	for _, c := range b {
+		if c != d.keys[d.matched] {
+			out = append(out, d.keys[:d.matched]...)
+			d.matched = 0
+			if c != d.keys[0] {
+				out = append(out, c)
+				continue
+			}
+		}
+
+		d.matched++
+		if d.matched == len(d.keys) {
+			d.matched = 0
+			return out, true
+		}
+	}
+
+	return out, false
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"spm/pkg/config"
+	"spm/pkg/supervisor"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Validate Procfile and Procfile.options",
+	Run:   execCheckCmd,
+}
+
+func init() {
+	setupCommandPreRun(checkCmd, nil)
+	rootCmd.AddCommand(checkCmd)
+}
+
+func execCheckCmd(cmd *cobra.Command, args []string) {
+	problems := supervisor.CheckProcfile(config.WorkDirFlag, config.ProcfileFlag)
+	if len(problems) == 0 {
+		fmt.Println("Configuration OK")
+		return
+	}
+
+	errCount := 0
+	for _, p := range problems {
+		if p.Severity == supervisor.SeverityError {
+			errCount++
+		}
+		fmt.Println(p)
+	}
+
+	fmt.Printf("\n%d error(s), %d warning(s)\n", errCount, len(problems)-errCount)
+
+	if supervisor.HasErrors(problems) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"spm/pkg/config"
+	"spm/pkg/supervisor"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [--process name] -- command [args...]",
+	Short: "Run a one-off command in the project environment",
+	Long: `Run a one-off command with the project's merged environment, attached to the
+current terminal. The command is not supervised; spm exits with its exit code.
+
+With --process the command also uses that process's root, env and user settings.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  execExecCmd,
+
+	SilenceUsage: true,
+}
+
+var execProcess string
+
+func init() {
+	execCmd.Flags().StringVar(&execProcess, "process", "", "Use the root, env and user of this process from the Procfile")
+	// exec 之后的参数都属于要运行的命令
+	execCmd.Flags().SetInterspersed(false)
+
+	setupCommandPreRun(execCmd, nil)
+	rootCmd.AddCommand(execCmd)
+}
+
+func execExecCmd(cmd *cobra.Command, args []string) {
+	if isRemote() {
+		fmt.Fprintln(os.Stderr, "ERROR: exec runs the command on this host, --host is not supported")
+		os.Exit(1)
+	}
+
+	opts, err := supervisor.LoadProcfileOption(config.WorkDirFlag, config.ProcfileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	c, err := supervisor.ExecCommand(opts, execProcess, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	// 终端的 Ctrl-C 和 Ctrl-\ 会直接发给命令，spm 只等待命令退出；
+	// 单独发给 spm 的 TERM 和 HUP 转发给命令
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP)
+
+	if err := c.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(127)
+	}
+
+	go func() {
+		for sig := range sigs {
+			if sig == syscall.SIGTERM || sig == syscall.SIGHUP {
+				_ = c.Process.Signal(sig)
+			}
+		}
+	}()
+
+	err = c.Wait()
+	signal.Stop(sigs)
+
+	os.Exit(exitCode(err))
+}
+
+// exitCode 按 shell 的约定返回命令的退出码，被信号终止时为 128+信号值
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+
+	return exitErr.ExitCode()
+}
@@ -7,14 +7,14 @@ import (
 	"strings"
 	"syscall"
 
+	"spm/pkg/config"
 	"spm/pkg/utils"
-	"spm/pkg/utils/constants"
 
 	"github.com/spf13/cobra"
 )
 
 func isDaemonRunning() bool {
-	daemonPid, err := utils.ReadPid(constants.DaemonPidFilePath)
+	daemonPid, err := utils.ReadPid(config.GetConfig().PidFile)
 	if err != nil {
 		return false
 	}
@@ -26,6 +26,11 @@ func isDaemonRunning() bool {
 	return isPidActive(daemonPid)
 }
 
+// isRemote 判断是否通过 --host 或 SPM_HOST 控制远程守护进程
+func isRemote() bool {
+	return config.GetConfig().Client.Host != ""
+}
+
 func isPidActive(p int) bool {
 	_, err := syscall.Getpgid(p)
 
@@ -86,6 +91,8 @@ func parseProcessArgs(args []string, separator string) string {
 //  1. 检查守护进程状态
 //  2. 如果未运行，打印错误并退出程序
 //
+// 通过 --host 控制远程守护进程时不检查本机的 PID 文件，连接失败时由请求返回错误
+//
 // 使用场景：
 //
 //	在需要守护进程运行的命令中（stop/restart/status/shutdown/reload）
@@ -97,6 +104,10 @@ func parseProcessArgs(args []string, separator string) string {
 //	    requireDaemonRunning()
 //	}
 func requireDaemonRunning() {
+	if isRemote() {
+		return
+	}
+
 	if !isDaemonRunning() {
 		log.Fatalln("ERROR: Supervisor has not started. Please check supervisor daemon.")
 	}
@@ -1,13 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"log"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"spm/pkg/client"
 	"spm/pkg/config"
+	"spm/pkg/supervisor"
+)
+
+var (
+	reloadDryRun bool
+	reloadJSON   bool
 )
 
 var reloadCmd = &cobra.Command{
@@ -17,18 +27,64 @@ var reloadCmd = &cobra.Command{
 }
 
 func init() {
+	reloadCmd.Flags().BoolVar(&reloadDryRun, "dry-run", false, "Show what would change without touching any process")
+	reloadCmd.Flags().BoolVar(&reloadJSON, "json", false, "Print the changes as JSON")
+
 	setupCommandPreRun(reloadCmd, requireDaemonRunning)
 	rootCmd.AddCommand(reloadCmd)
 }
 
 func execReloadCmd(cmd *cobra.Command, args []string) {
-	res := client.Reload(config.WorkDirFlag, config.ProcfileFlag)
-	if res == nil {
-		fmt.Println("No processes changed")
+	// 重启进程可能需要等待较长时间，不设置超时
+	c := client.New(client.WithProject(config.WorkDirFlag, config.ProcfileFlag), client.WithTimeout(0))
+
+	res, err := c.Reload(context.Background(), reloadDryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	if reloadJSON {
+		printReloadJSON(res.Changes)
 		return
 	}
 
-	for _, proc := range res {
-		fmt.Printf("[%s] Load %s\t%s\n", time.Now().Format(time.RFC3339), proc.Name, proc.Status)
+	changed := false
+	for _, c := range res.Changes {
+		if c.Action == supervisor.ReloadNone {
+			continue
+		}
+		changed = true
+
+		if reloadDryRun {
+			fmt.Printf("%-8s %s\t%s\n", c.Action, c.Name, c.Status)
+		} else {
+			fmt.Printf("[%s] %-8s %s\t%s\n", time.Now().Format(time.RFC3339), c.Action, c.Name, c.Status)
+		}
+
+		for _, f := range c.Fields {
+			if f.Old != "" {
+				fmt.Printf("\t%s: %q -> %q\n", f.Field, f.Old, f.New)
+			} else {
+				fmt.Printf("\t%s: %s\n", f.Field, f.New)
+			}
+		}
+	}
+
+	if !changed {
+		fmt.Println("No processes changed")
+	}
+}
+
+// printReloadJSON 以 JSON 输出变更列表，标准输出只有 JSON，错误在调用前已输出到标准错误
+func printReloadJSON(changes []*supervisor.ProcChange) {
+	if changes == nil {
+		changes = make([]*supervisor.ProcChange, 0)
+	}
+
+	data, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		log.Fatal(err)
 	}
+	fmt.Println(string(data))
 }
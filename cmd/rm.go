@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"spm/pkg/client"
+	"spm/pkg/config"
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm name [name...]",
+	Short: "Stop and remove processes started by spm run",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   execRmCmd,
+}
+
+func init() {
+	setupCommandPreRun(rmCmd, requireDaemonRunning)
+	rootCmd.AddCommand(rmCmd)
+}
+
+func execRmCmd(cmd *cobra.Command, args []string) {
+	res := client.Remove(config.WorkDirFlag, config.ProcfileFlag, args...)
+	if res == nil {
+		return
+	}
+
+	for _, proc := range res {
+		fmt.Printf("[%s] Removed %s\t[PID %d] %s\n", time.Now().Format(time.RFC3339), proc.Name, proc.Pid, proc.Status)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"spm/pkg/config"
+	"spm/pkg/supervisor"
 	"spm/pkg/utils"
 	"spm/pkg/utils/constants"
 
@@ -38,6 +39,12 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	// 守护进程以辅助模式启动设置了 rlimits 的进程，不经过命令行解析和配置加载
+	if len(os.Args) > 1 && os.Args[1] == supervisor.ExecHelperArg {
+		supervisor.ExecHelper(os.Args[2:])
+	}
+
+	utils.BinaryVersion = Version
 	cobra.CheckErr(rootCmd.Execute())
 }
 
@@ -62,13 +69,40 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&config.LogLevelFlag, "loglevel", "l", constants.DefaultLogLevel, "Set log Level")
 	rootCmd.PersistentFlags().StringVarP(&config.WorkDirFlag, "workdir", "w", cwd, "The path to the work directory")
 	rootCmd.PersistentFlags().StringVarP(&config.ProcfileFlag, "procfile", "p", defaultProcfile, "The path to the Procfile")
+	rootCmd.PersistentFlags().StringVarP(&config.HostFlag, "host", "H", "", "Control a remote supervisor at host[:port] over TLS (env SPM_HOST)")
 
 	// Register persistent function for all commands
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
-		execRootPersistentPreRun()
+		execRootPersistentPreRun(cmd)
 	}
 }
 
-func execRootPersistentPreRun() {
+func execRootPersistentPreRun(cmd *cobra.Command) {
 	utils.InitEnv()
+
+	cfg := config.GetConfig()
+	if config.HostFlag != "" {
+		cfg.Client.Host = config.HostFlag
+	}
+
+	// 守护进程、前台运行和 check 只作用于本机，忽略远程地址
+	if cmd == daemonCmd || cmd == checkCmd || config.ForegroundFlag {
+		cfg.Client.Host = ""
+		return
+	}
+
+	// 远程控制时本机的当前目录没有意义，-w 和 -p 指的是远程主机上的路径；
+	// 都没有指定时不发送项目目录，由守护进程按所有项目处理
+	if cfg.Client.Host != "" {
+		workDirSet := cmd.Flags().Changed("workdir")
+		if !workDirSet {
+			config.WorkDirFlag = ""
+		}
+		if !cmd.Flags().Changed("procfile") {
+			config.ProcfileFlag = ""
+			if workDirSet {
+				config.ProcfileFlag = config.WorkDirFlag + "/Procfile"
+			}
+		}
+	}
 }
@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -12,14 +14,37 @@ import (
 )
 
 var runCmd = &cobra.Command{
-	Use:   "run",
+	Use:   "run [--name name] [--env KEY=VALUE]... [--restart policy] [--tty] -- command [args...]",
 	Short: "Run command as a process",
-	Run:   execRunCmd,
+	Long: `Run a command as a supervised process of the current project.
+
+The process is named after the executable unless --name is given; when the name
+is taken a -2, -3 ... suffix is added. Run processes are not in the Procfile, so
+reload leaves them alone. Remove them with "spm rm".
+
+With --tty the process runs in a pseudo-terminal; connect to it with "spm attach".
+
+Restart policies: never (default), on-failure, always.`,
+	Run: execRunCmd,
 
 	SilenceUsage: true,
 }
 
+var (
+	runName    string
+	runEnv     []string
+	runRestart string
+	runTty     bool
+)
+
 func init() {
+	runCmd.Flags().StringVar(&runName, "name", "", "Process name, defaults to the executable name")
+	runCmd.Flags().StringArrayVarP(&runEnv, "env", "e", nil, "Set an environment variable KEY=VALUE, can be repeated")
+	runCmd.Flags().StringVar(&runRestart, "restart", "never", "Restart policy when the process exits: never, on-failure, always")
+	runCmd.Flags().BoolVarP(&runTty, "tty", "t", false, "Run the process in a pseudo-terminal, connect to it with spm attach")
+	// run 之后的参数都属于要运行的命令
+	runCmd.Flags().SetInterspersed(false)
+
 	runCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
 		rootCmd.PersistentPreRun(cmd, args)
 		execRunPersistentPreRun()
@@ -40,7 +65,18 @@ func execRunCmd(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	res := client.Run(config.WorkDirFlag, config.ProcfileFlag, args)
+	env := make(map[string]string, len(runEnv))
+	for _, kv := range runEnv {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			fmt.Fprintf(os.Stderr, "ERROR: invalid --env %q, expected KEY=VALUE\n", kv)
+			os.Exit(1)
+		}
+		env[k] = v
+	}
+
+	opts := client.RunOptions{Name: runName, Env: env, Restart: runRestart, Tty: runTty}
+	res := client.RunWithOptions(config.WorkDirFlag, config.ProcfileFlag, opts, args)
 	if res == nil {
 		fmt.Println("No processes to run.")
 		return
@@ -1,13 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"spm/pkg/client"
-	"spm/pkg/config"
 )
 
 var shutdownCmd = &cobra.Command{
@@ -23,15 +26,21 @@ func init() {
 
 func execShutdownCmd(cmd *cobra.Command, args []string) {
 	// 使用 channel 异步执行 RPC 调用
+	var err error
 	done := make(chan struct{})
 	go func() {
-		_ = client.Shutdown(config.WorkDirFlag, config.ProcfileFlag)
+		_, err = client.New().Shutdown(context.Background())
 		close(done)
 	}()
 
 	// 等待 RPC 响应或超时
 	select {
 	case <-done:
+		// 守护进程可能在发送响应前关闭连接，不视为错误
+		if err != nil && !errors.Is(err, io.EOF) {
+			_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
 		fmt.Println("Supervisor service has been stopped.")
 	case <-time.After(5 * time.Second):
 		fmt.Println("Shutdown initiated (timeout waiting for response).")
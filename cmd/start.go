@@ -18,12 +18,15 @@ var startCmd = &cobra.Command{
 	Run:   execStartCmd,
 }
 
+var startWatch bool
+
 func init() {
 	startCmd.PersistentFlags().BoolVarP(&config.ForegroundFlag, "foreground", "f", false, "Run the supervisor in the foreground")
+	startCmd.Flags().BoolVar(&startWatch, "watch", false, "Restart processes when files under their root change, until they are stopped")
 
 	// start命令特殊处理：尝试启动daemon而不是要求daemon已运行
 	setupCommandPreRun(startCmd, func() {
-		if !config.ForegroundFlag {
+		if !config.ForegroundFlag && !isRemote() {
 			if isDaemonRunning() {
 				return
 			}
@@ -41,7 +44,12 @@ func init() {
 
 func execStartCmd(cmd *cobra.Command, args []string) {
 	sendStartCmd := func(args []string) {
-		res := client.Start(config.WorkDirFlag, config.ProcfileFlag, args...)
+		var res []*supervisor.ProcInfo
+		if startWatch {
+			res = client.StartWatch(config.WorkDirFlag, config.ProcfileFlag, args...)
+		} else {
+			res = client.Start(config.WorkDirFlag, config.ProcfileFlag, args...)
+		}
 		if res == nil {
 			fmt.Println("No processes to start.")
 			return
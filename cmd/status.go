@@ -2,11 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"spm/pkg/client"
 	"spm/pkg/config"
+	"spm/pkg/supervisor"
 )
 
 var statusCmd = &cobra.Command{
@@ -15,19 +20,131 @@ var statusCmd = &cobra.Command{
 	Run:   execStatusCmd,
 }
 
+var statusVerbose bool
+
 func init() {
+	statusCmd.Flags().BoolVar(&statusVerbose, "verbose", false, "Show command, user and resource limits of each process")
+
 	setupCommandPreRun(statusCmd, requireDaemonRunning)
 	rootCmd.AddCommand(statusCmd)
 }
 
 func execStatusCmd(cmd *cobra.Command, args []string) {
-	res := client.Status(config.WorkDirFlag, config.ProcfileFlag, args...)
+	var res []*supervisor.ProcInfo
+	if statusVerbose {
+		res = client.StatusVerbose(config.WorkDirFlag, config.ProcfileFlag, args...)
+	} else {
+		res = client.Status(config.WorkDirFlag, config.ProcfileFlag, args...)
+	}
 	if res == nil {
 		fmt.Println("No processes found.")
 		return
 	}
 
+	// spm run 注册的临时进程列在 Procfile 中的进程之后
+	runProcs := make([]*supervisor.ProcInfo, 0)
 	for _, proc := range res {
-		fmt.Printf("%s\t\t%s\t\tPID: %d\n", proc.Name, proc.Status, proc.Pid)
+		if proc.Run != nil {
+			runProcs = append(runProcs, proc)
+			continue
+		}
+		printProcInfo(proc)
+	}
+
+	if len(runProcs) > 0 {
+		if len(runProcs) < len(res) {
+			fmt.Println()
+		}
+		fmt.Println("Run processes (remove with spm rm):")
+		for _, proc := range runProcs {
+			printProcInfo(proc)
+		}
+	}
+}
+
+// printProcInfo 输出单个进程的状态及其附带的信息
+func printProcInfo(proc *supervisor.ProcInfo) {
+	fmt.Printf("%s\t\t%s\t\tPID: %d\n", proc.Name, proc.Status, proc.Pid)
+	if proc.Run != nil {
+		fmt.Printf("    run:      %s (restart %s", proc.Run.Command, proc.Run.Restart)
+		if proc.Run.Restarts > 0 {
+			fmt.Printf(", restarts=%d", proc.Run.Restarts)
+		}
+		fmt.Println(")")
+	}
+	if proc.Job != nil {
+		printJobInfo(proc.Job)
+	}
+	if proc.Detail != nil {
+		printProcDetail(proc.Detail)
+	}
+}
+
+// printProcDetail 输出 status --verbose 的进程详细信息
+func printProcDetail(d *supervisor.ProcDetail) {
+	fmt.Printf("    command:  %s\n", d.Command)
+	fmt.Printf("    root:     %s\n", d.Root)
+	if d.User != "" {
+		fmt.Printf("    user:     %s\n", d.User)
+	}
+
+	if len(d.Rlimits) > 0 {
+		fmt.Printf("    rlimits:  %s\n", joinSorted(d.Rlimits))
+	}
+
+	if len(d.Sched) > 0 {
+		fmt.Printf("    sched:    %s\n", joinSorted(d.Sched))
 	}
+
+	if len(d.Limits) > 0 {
+		fmt.Printf("    limits:   %s\n", joinSorted(d.Limits))
+	}
+
+	if d.Cgroup != "" {
+		fmt.Printf("    cgroup:   %s\n", d.Cgroup)
+	}
+
+	if u := d.Usage; u != nil {
+		fmt.Printf("    usage:    cpu=%.2fs rss=%.1fMiB fds=%d threads=%d", u.CPUSeconds, float64(u.RSSBytes)/(1<<20), u.FDs, u.Threads)
+		if u.Procs > 0 {
+			fmt.Printf(" procs=%d", u.Procs)
+		}
+		fmt.Println()
+	}
+}
+
+// printJobInfo 输出定时任务的调度规则、下一次运行时间和最近一次运行的结果
+func printJobInfo(j *supervisor.JobInfo) {
+	next := "-"
+	if j.NextRun > 0 {
+		next = time.UnixMilli(j.NextRun).Format(time.RFC3339)
+	}
+	fmt.Printf("    schedule: %s (overlap %s), next run: %s", j.Schedule, j.Overlap, next)
+	if j.Running > 1 {
+		fmt.Printf(", %d running", j.Running)
+	}
+	if j.Queued {
+		fmt.Print(", queued")
+	}
+	fmt.Println()
+
+	if r := j.LastRun; r != nil {
+		fmt.Printf("    last run: %s exit=%d duration=%s", time.UnixMilli(r.StartAt).Format(time.RFC3339),
+			r.ExitCode, (time.Duration(r.Duration) * time.Millisecond).String())
+		if r.Message != "" {
+			fmt.Printf(" (%s)", r.Message)
+		}
+		fmt.Println()
+	}
+}
+
+// joinSorted 把映射按键排序后格式化为 "k=v k=v" 的形式
+func joinSorted(m map[string]string) string {
+	names := slices.Sorted(maps.Keys(m))
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+m[name])
+	}
+
+	return strings.Join(parts, " ")
 }
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"spm/pkg/client"
+	"spm/pkg/tui"
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Interactive view of all projects and processes",
+	Args:  cobra.NoArgs,
+	Run:   execTopCmd,
+}
+
+var topInterval time.Duration
+
+func init() {
+	topCmd.Flags().DurationVar(&topInterval, "interval", time.Second, "Refresh interval")
+
+	setupCommandPreRun(topCmd, requireDaemonRunning)
+	rootCmd.AddCommand(topCmd)
+}
+
+func execTopCmd(cmd *cobra.Command, args []string) {
+	c := client.New(client.WithTimeout(0))
+
+	if err := tui.Top(context.Background(), c, tui.Options{Interval: topInterval}); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
@@ -8,6 +8,9 @@
 //  2. 消除重复的消息构造逻辑
 //  3. 提供易于测试和维护的接口
 //  4. 为未来扩展（如 HTTP API）预留空间
+//
+// 包级别的 Start/Stop 等函数面向命令行，直接输出结果；
+// 作为库在其他 Go 程序中使用时，请使用 Client，它返回类型化的响应和错误。
 package client
 
 import (
@@ -46,6 +49,15 @@ func Start(workDir, procfile string, processes ...string) []*supervisor.ProcInfo
 	return supervisor.ClientRun(msg)
 }
 
+// StartWatch 启动进程并开启文件监控，进程 Root 下匹配 watch 配置的文件变化后自动重启
+//
+// 参数与 Start 相同，文件监控在 spm stop 停止进程或守护进程退出时关闭
+func StartWatch(workDir, procfile string, processes ...string) []*supervisor.ProcInfo {
+	msg := buildActionMsg(supervisor.ActionStart, workDir, procfile, processes)
+	msg.Watch = true
+	return supervisor.ClientRun(msg)
+}
+
 // Stop 停止一个或多个进程
 //
 // 参数：
@@ -135,6 +147,13 @@ func Status(workDir, procfile string, processes ...string) []*supervisor.ProcInf
 	return supervisor.ClientRun(msg)
 }
 
+// StatusVerbose 与 Status 相同，但每个进程附带 Detail（命令、目录、用户和资源限制等）
+func StatusVerbose(workDir, procfile string, processes ...string) []*supervisor.ProcInfo {
+	msg := buildActionMsg(supervisor.ActionStatus, workDir, procfile, processes)
+	msg.Verbose = true
+	return supervisor.ClientRun(msg)
+}
+
 // Reload 重新加载配置并重启受影响的进程
 //
 // 参数：
@@ -144,24 +163,63 @@ func Status(workDir, procfile string, processes ...string) []*supervisor.ProcInf
 //
 // 返回：
 //
-//	[]*supervisor.ProcInfo: 重新加载的进程信息列表
-//	  - 如果没有配置变化，返回 nil
-//	  - 只有配置发生变化的进程会被重启
+//	[]*supervisor.ProcChange: 每个进程的变更结果
+//	  - 如果请求失败，返回 nil
+//	  - Change 字段为 added/changed/removed/unchanged
+//	  - Fields 字段列出 changed 进程中变化的配置项
 //
 // 使用示例：
 //
-//	infos := client.Reload("/path/to/workdir", "Procfile")
+//	changes := client.Reload("/path/to/workdir", "Procfile")
 //
 // 注意事项：
-//   - 此操作会比较新旧配置，只重启发生变化的进程
+//   - 此操作会比较新旧配置，只重启配置发生变化的运行中进程
+//   - 从 Procfile 中移除的进程会被停止
 //   - 未变化的进程保持运行，不受影响
-func Reload(workDir, procfile string) []*supervisor.ProcInfo {
+func Reload(workDir, procfile string) []*supervisor.ProcChange {
 	msg := &supervisor.ActionMsg{
 		Action:   supervisor.ActionReload,
 		WorkDir:  workDir,
 		Procfile: procfile,
 	}
-	return supervisor.ClientRun(msg)
+
+	res := supervisor.ClientSend(msg)
+	if res == nil {
+		return nil
+	}
+
+	return res.Changes
+}
+
+// ReloadDryRun 计算重新加载配置的变更计划，不影响任何运行中的进程
+//
+// 参数：
+//
+//	workDir: 工作目录路径
+//	procfile: Procfile 配置文件路径
+//
+// 返回：
+//
+//	[]*supervisor.ProcChange: 每个进程将要执行的操作（Action）及变化的配置项
+//	  - 如果请求失败或项目未注册，返回 nil
+//
+// 使用示例：
+//
+//	plan := client.ReloadDryRun("/path/to/workdir", "Procfile")
+func ReloadDryRun(workDir, procfile string) []*supervisor.ProcChange {
+	msg := &supervisor.ActionMsg{
+		Action:   supervisor.ActionReload,
+		WorkDir:  workDir,
+		Procfile: procfile,
+		DryRun:   true,
+	}
+
+	res := supervisor.ClientSend(msg)
+	if res == nil {
+		return nil
+	}
+
+	return res.Changes
 }
 
 // Shutdown 关闭 supervisor daemon
@@ -213,19 +271,80 @@ func Shutdown(workDir, procfile string) []*supervisor.ProcInfo {
 //
 // 注意事项：
 //   - 此命令会将临时命令注册为 supervisor 管理的进程
-//   - 进程名称自动从可执行文件名提取
+//   - 进程名称自动从可执行文件名提取，已被占用时添加 -2、-3 等后缀
 //   - 命令会在 workDir 目录下执行
 func Run(workDir, procfile string, cmdLine []string) []*supervisor.ProcInfo {
+	return RunWithOptions(workDir, procfile, RunOptions{}, cmdLine)
+}
+
+// RunOptions spm run 注册临时进程时的可选参数
+type RunOptions struct {
+	Name    string            // 进程名，为空时使用可执行文件名
+	Env     map[string]string // 进程的环境变量
+	Restart string            // 进程自己退出后的重启策略：never（默认）、on-failure、always
+	Tty     bool              // 在伪终端中运行，可以用 spm attach 连接
+}
+
+// RunWithOptions 将一个命令以指定的名称、环境变量和重启策略作为进程运行
+//
+// 参数：
+//
+//	workDir: 工作目录路径
+//	procfile: Procfile 配置文件路径
+//	opts: 进程名、环境变量和重启策略
+//	cmdLine: 要执行的命令及其参数
+//
+// 返回：
+//
+//	[]*supervisor.ProcInfo: 运行的进程信息列表，失败时返回 nil
+//
+// 使用示例：
+//
+//	infos := client.RunWithOptions("/path/to/workdir", "Procfile",
+//	    client.RunOptions{Name: "api", Restart: "on-failure"},
+//	    []string{"python3", "api.py"})
+//
+// 注意事项：
+//   - 指定的名称已被 Procfile 中的进程或运行中的临时进程占用时返回 409
+//   - 同名的临时进程已经停止时，用新的命令替换它
+func RunWithOptions(workDir, procfile string, opts RunOptions, cmdLine []string) []*supervisor.ProcInfo {
 	msg := &supervisor.ActionMsg{
-		Action:    supervisor.ActionRun,
-		WorkDir:   workDir,
-		Procfile:  procfile,
-		CmdLine:   cmdLine,
-		Processes: "", // Run 命令不需要指定进程名
+		Action:   supervisor.ActionRun,
+		WorkDir:  workDir,
+		Procfile: procfile,
+		CmdLine:  cmdLine,
+		Name:     opts.Name,
+		Env:      opts.Env,
+		Tty:      opts.Tty,
+		Restart:  opts.Restart,
 	}
 	return supervisor.ClientRun(msg)
 }
 
+// Remove 停止并注销 spm run 注册的临时进程
+//
+// 参数：
+//
+//	workDir: 工作目录路径
+//	procfile: Procfile 配置文件路径
+//	processes: 进程名列表，不能为空
+//
+// 返回：
+//
+//	[]*supervisor.ProcInfo: 已移除的进程信息列表，失败时返回 nil
+//
+// 使用示例：
+//
+//	infos := client.Remove("/path/to/workdir", "Procfile", "api")
+//
+// 注意事项：
+//   - 只能移除临时进程，Procfile 中的进程需要修改 Procfile 后 reload
+//   - 有任意一个进程不存在或不是临时进程时，不会移除任何进程
+func Remove(workDir, procfile string, processes ...string) []*supervisor.ProcInfo {
+	msg := buildActionMsg(supervisor.ActionRemove, workDir, procfile, processes)
+	return supervisor.ClientRun(msg)
+}
+
 // buildActionMsg 内部辅助函数，构建 ActionMsg 消息
 //
 // 功能：
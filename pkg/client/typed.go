@@ -0,0 +1,345 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"spm/pkg/config"
+	"spm/pkg/supervisor"
+	"spm/pkg/utils/constants"
+)
+
+// DefaultTimeout 单个请求的默认超时时间
+//
+// stop、restart、reload、rm 和 shutdown 要等待进程退出，每个进程最长需要 stopTimeout，
+// 总耗时可能远超 DefaultTimeout，因此这些操作在没有调用 WithTimeout 时不设默认超时，只受 ctx 控制
+const DefaultTimeout = 30 * time.Second
+
+// ErrDaemonUnavailable 无法连接到守护进程，可以用 errors.Is 判断
+var ErrDaemonUnavailable = supervisor.ErrDaemonUnavailable
+
+// Response 守护进程的响应
+//
+// Code 沿用 HTTP 状态码的含义：200 成功，400 请求缺少必要的参数，401 远程连接的令牌无效，403 没有权限，
+// 404 进程或项目不存在，409 进程名已被占用或不能移除，422 配置错误，426 版本不一致，500 其他错误
+type Response struct {
+	Code      int
+	Message   string
+	Processes []*supervisor.ProcInfo
+	Changes   []*supervisor.ProcChange
+}
+
+// ResponseError 守护进程返回了非 2xx 的状态码
+type ResponseError struct {
+	Code    int
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("daemon returned %d: %s", e.Code, e.Message)
+}
+
+// IsNotFound 判断错误是否表示进程或项目不存在
+func IsNotFound(err error) bool {
+	var resErr *ResponseError
+	return errors.As(err, &resErr) && resErr.Code == 404
+}
+
+// Client 面向 Go 程序的 spm 客户端
+//
+// 与包级别的 Start/Stop 等函数不同，Client 的方法不输出任何信息，
+// 而是返回 (*Response, error)，调用方可以据此区分：
+//   - 守护进程没有运行：errors.Is(err, client.ErrDaemonUnavailable)
+//   - 进程不存在：client.IsNotFound(err)
+//   - 版本不一致：errors.As(err, new(*supervisor.VersionMismatchError))
+//
+// 使用示例：
+//
+//	c := client.New(client.WithProject("/srv/app", "/srv/app/Procfile"))
+//	res, err := c.Status(ctx, "web")
+//	if client.IsNotFound(err) {
+//	    // ...
+//	}
+type Client struct {
+	endpoint   *supervisor.Endpoint
+	err        error // 根据 spm.yml 创建 endpoint 失败的原因，在发送请求时返回
+	timeout    time.Duration
+	timeoutSet bool // 是否调用过 WithTimeout
+	workDir    string
+	procfile   string
+}
+
+// Option Client 的配置项
+type Option func(*Client)
+
+// WithSocket 设置控制套接字路径，默认使用 spm.yml 中的 socket 配置
+func WithSocket(path string) Option {
+	return func(c *Client) {
+		c.endpoint = &supervisor.Endpoint{Network: "unix", Address: path}
+		c.err = nil
+	}
+}
+
+// WithEndpoint 设置请求目标，用于通过 TLS 连接远程守护进程
+//
+// 默认根据 spm.yml 的 client 配置（以及 SPM_HOST、SPM_TOKEN 环境变量）创建，
+// 见 supervisor.NewEndpoint
+func WithEndpoint(ep *supervisor.Endpoint) Option {
+	return func(c *Client) {
+		c.endpoint = ep
+		c.err = nil
+	}
+}
+
+// WithTimeout 设置单个请求的超时时间，0 表示只受 ctx 控制
+//
+// 设置后对所有操作生效，包括需要等待进程退出的 stop、restart 等操作
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+		c.timeoutSet = true
+	}
+}
+
+// WithProject 设置请求所针对的项目目录和 Procfile
+func WithProject(workDir, procfile string) Option {
+	return func(c *Client) {
+		c.workDir = workDir
+		c.procfile = procfile
+	}
+}
+
+// New 创建 Client
+func New(opts ...Option) *Client {
+	c := &Client{
+		endpoint: &supervisor.Endpoint{Network: "unix", Address: constants.DaemonSockFilePath},
+		timeout:  DefaultTimeout,
+	}
+
+	if cfg := config.GetConfig(); cfg != nil {
+		if cfg.Client.Host != "" || cfg.Socket != "" {
+			c.endpoint, c.err = supervisor.NewEndpoint(cfg)
+		}
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Do 发送任意控制消息，未设置 WorkDir 和 Procfile 时使用 WithProject 的值，不会修改 msg
+func (c *Client) Do(ctx context.Context, msg *supervisor.ActionMsg) (*Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	m := *msg
+	if m.WorkDir == "" {
+		m.WorkDir = c.workDir
+	}
+	if m.Procfile == "" {
+		m.Procfile = c.procfile
+	}
+
+	timeout := c.timeout
+	if !c.timeoutSet && waitsForExit(m.Action) {
+		timeout = 0
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	res, err := supervisor.RequestTo(ctx, c.endpoint, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{
+		Code:      res.Code,
+		Message:   res.Message,
+		Processes: res.Processes,
+		Changes:   res.Changes,
+	}
+
+	if res.Code < 200 || res.Code >= 300 {
+		return resp, &ResponseError{Code: res.Code, Message: res.Message}
+	}
+
+	return resp, nil
+}
+
+// waitsForExit 判断操作是否需要等待进程退出，耗时取决于进程数量和 stopTimeout
+func waitsForExit(action supervisor.ActionCtl) bool {
+	switch action {
+	case supervisor.ActionStop, supervisor.ActionRestart, supervisor.ActionReload,
+		supervisor.ActionRemove, supervisor.ActionShutdown:
+		return true
+	}
+
+	return false
+}
+
+// Start 启动进程，processes 为空时启动项目中的所有进程
+func (c *Client) Start(ctx context.Context, processes ...string) (*Response, error) {
+	return c.Do(ctx, buildActionMsg(supervisor.ActionStart, "", "", processes))
+}
+
+// StartWatch 启动进程并开启文件监控，processes 为空时启动项目中的所有进程
+func (c *Client) StartWatch(ctx context.Context, processes ...string) (*Response, error) {
+	msg := buildActionMsg(supervisor.ActionStart, "", "", processes)
+	msg.Watch = true
+	return c.Do(ctx, msg)
+}
+
+// Stop 停止进程，processes 为空时停止项目中的所有进程
+func (c *Client) Stop(ctx context.Context, processes ...string) (*Response, error) {
+	return c.Do(ctx, buildActionMsg(supervisor.ActionStop, "", "", processes))
+}
+
+// Restart 重启进程，processes 为空时重启项目中的所有进程
+func (c *Client) Restart(ctx context.Context, processes ...string) (*Response, error) {
+	return c.Do(ctx, buildActionMsg(supervisor.ActionRestart, "", "", processes))
+}
+
+// Status 查询进程状态，processes 为空时查询项目中的所有进程
+func (c *Client) Status(ctx context.Context, processes ...string) (*Response, error) {
+	return c.Do(ctx, buildActionMsg(supervisor.ActionStatus, "", "", processes))
+}
+
+// StatusVerbose 查询进程状态，每个进程附带 Detail（命令、目录、用户和资源限制等）
+func (c *Client) StatusVerbose(ctx context.Context, processes ...string) (*Response, error) {
+	msg := buildActionMsg(supervisor.ActionStatus, "", "", processes)
+	msg.Verbose = true
+	return c.Do(ctx, msg)
+}
+
+// Reload 重新加载 WithProject 指定的项目配置，dryRun 为 true 时只返回变更计划
+//
+// 没有调用 WithProject 时返回错误，按项目名重载使用 ReloadProjects
+func (c *Client) Reload(ctx context.Context, dryRun bool) (*Response, error) {
+	if c.workDir == "" || c.procfile == "" {
+		return nil, errors.New("reload requires WithProject, use ReloadProjects to reload by project name")
+	}
+
+	return c.Do(ctx, &supervisor.ActionMsg{
+		Action: supervisor.ActionReload,
+		DryRun: dryRun,
+	})
+}
+
+// ReloadProjects 按项目名重新加载配置，配置从项目注册时记录的目录读取
+//
+// 项目名即 spm status 中进程名 :: 之前的部分，任一项目不存在时返回 IsNotFound 为 true 的错误
+func (c *Client) ReloadProjects(ctx context.Context, dryRun bool, projects ...string) (*Response, error) {
+	if len(projects) == 0 {
+		return nil, errors.New("no project to reload")
+	}
+
+	return c.Do(ctx, &supervisor.ActionMsg{
+		Action:   supervisor.ActionReload,
+		Projects: strings.Join(projects, ";"),
+		DryRun:   dryRun,
+	})
+}
+
+// Run 把命令作为受管理的进程运行
+func (c *Client) Run(ctx context.Context, cmdLine ...string) (*Response, error) {
+	return c.Do(ctx, &supervisor.ActionMsg{
+		Action:  supervisor.ActionRun,
+		CmdLine: cmdLine,
+	})
+}
+
+// RunWithOptions 把命令以指定的名称、环境变量和重启策略作为受管理的进程运行
+func (c *Client) RunWithOptions(ctx context.Context, opts RunOptions, cmdLine ...string) (*Response, error) {
+	return c.Do(ctx, &supervisor.ActionMsg{
+		Action:  supervisor.ActionRun,
+		CmdLine: cmdLine,
+		Name:    opts.Name,
+		Env:     opts.Env,
+		Restart: opts.Restart,
+		Tty:     opts.Tty,
+	})
+}
+
+// Remove 停止并注销 spm run 注册的临时进程
+func (c *Client) Remove(ctx context.Context, processes ...string) (*Response, error) {
+	return c.Do(ctx, buildActionMsg(supervisor.ActionRemove, "", "", processes))
+}
+
+// Signal 向进程发送信号，sig 可以写成 HUP、SIGUSR1 或数字
+func (c *Client) Signal(ctx context.Context, sig string, processes ...string) (*Response, error) {
+	msg := buildActionMsg(supervisor.ActionSignal, "", "", processes)
+	msg.Signal = sig
+	return c.Do(ctx, msg)
+}
+
+// Logs 跟踪进程的日志，先返回最近 tail 行历史日志，之后每有一行新的输出调用一次 fn
+//
+// 调用会一直阻塞，直到 ctx 被取消、fn 返回错误或守护进程关闭连接；
+// 进程不存在时返回 IsNotFound 为 true 的错误
+func (c *Client) Logs(ctx context.Context, process string, tail int, fn func(*supervisor.LogLine) error) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	msg := &supervisor.ActionMsg{
+		Action:    supervisor.ActionLog,
+		WorkDir:   c.workDir,
+		Procfile:  c.procfile,
+		Processes: process,
+		Tail:      tail,
+	}
+
+	res, err := supervisor.StreamLogs(ctx, c.endpoint, msg, fn)
+	if err != nil {
+		return err
+	}
+	if res.Code < 200 || res.Code >= 300 {
+		return &ResponseError{Code: res.Code, Message: res.Message}
+	}
+
+	return nil
+}
+
+// Attach 连接在伪终端中运行的进程，input 发送键盘输入和窗口大小，进程的输出交给 output
+//
+// 调用会一直阻塞：进程退出时返回 nil，ctx 被取消（detach）时返回 ctx.Err()，进程继续运行；
+// 进程不存在时返回 IsNotFound 为 true 的错误，进程没有在伪终端中运行时返回 409
+func (c *Client) Attach(ctx context.Context, process string, input <-chan *supervisor.AttachMsg, output func([]byte) error) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	msg := &supervisor.ActionMsg{
+		Action:    supervisor.ActionAttach,
+		WorkDir:   c.workDir,
+		Procfile:  c.procfile,
+		Processes: process,
+	}
+
+	res, err := supervisor.AttachConsole(ctx, c.endpoint, msg, input, output)
+	if err != nil {
+		return err
+	}
+	if res.Code < 200 || res.Code >= 300 {
+		return &ResponseError{Code: res.Code, Message: res.Message}
+	}
+
+	return nil
+}
+
+// Shutdown 停止所有进程并关闭守护进程
+func (c *Client) Shutdown(ctx context.Context) (*Response, error) {
+	return c.Do(ctx, &supervisor.ActionMsg{
+		Action: supervisor.ActionShutdown,
+	})
+}
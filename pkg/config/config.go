@@ -3,7 +3,6 @@ package config
 import (
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,6 +24,97 @@ type Config struct {
 	Socket    string
 	Log       Log
 	Env       map[string]string
+	HTTP      HTTP
+	Access    Access
+	Remote    Remote
+	Client    Client
+	Cgroup    Cgroup
+}
+
+// Cgroup 进程的 cgroup v2 资源控制配置
+//
+// 启用后每个项目对应 Parent 下的一个子目录，每个进程对应项目目录下的一个叶子 cgroup，
+// Procfile.options 中的 memoryMax、cpuMax、pidsMax、ioWeight 写入对应的 cgroup。
+// 守护进程需要对 Parent 有写权限：以 root 运行，或者使用 systemd 委派（Delegate=yes）的子树
+type Cgroup struct {
+	Enabled bool
+	Mount   string // cgroup2 的挂载点，为空时从 /proc/self/mountinfo 查找
+	Parent  string // 所有项目 cgroup 的父目录，相对路径基于挂载点
+}
+
+// Remote 守护进程的 TCP 控制监听配置，使用与 Unix 套接字相同的控制协议
+//
+// TCP 连接必须使用 TLS，并且至少启用一种认证方式：
+//   - Tokens：客户端在握手时提交令牌，按令牌允许的操作检查权限
+//   - ClientCA：要求客户端提供由该 CA 签发的证书（mTLS）
+//
+// 两者都配置时同时要求客户端证书和令牌
+type Remote struct {
+	Enabled  bool
+	Listen   string // 监听地址，例如 0.0.0.0:7000
+	CertFile string // 服务端证书
+	KeyFile  string // 服务端私钥
+	ClientCA string // 验证客户端证书的 CA，为空时不要求客户端证书
+	Tokens   []RemoteToken
+}
+
+// RemoteToken 远程控制使用的令牌
+//
+// Actions 的可选值与 AccessRule 相同，为空时允许全部操作
+type RemoteToken struct {
+	Name    string // 令牌名称，用于日志和权限错误信息
+	Token   string
+	Actions []string
+}
+
+// Client 命令行客户端连接远程守护进程的配置
+//
+// Host 和 Token 也可以通过环境变量 SPM_HOST、SPM_TOKEN 设置，
+// Host 为空时连接本机的 Unix 套接字
+type Client struct {
+	Host       string // 远程守护进程地址，例如 box12:7000，省略端口时使用 7000
+	Token      string // 握手时提交的令牌
+	CAFile     string // 验证守护进程证书的 CA，为空时使用系统 CA
+	CertFile   string // mTLS 客户端证书
+	KeyFile    string // mTLS 客户端私钥
+	ServerName string // 校验证书时使用的主机名，为空时取 Host 中的主机名
+}
+
+// Access 控制套接字的访问控制配置，同时作用于监听 Unix 套接字的 HTTP 服务
+//
+// 守护进程的运行用户和 root 始终拥有全部权限，其他用户只能执行 Rules 中允许的操作，
+// 没有匹配的规则时拒绝访问
+type Access struct {
+	Mode  string // 套接字文件权限，八进制字符串，例如 "0660"，为空时不修改
+	Owner string // 套接字文件的所有者，用户名或 UID，为空时不修改
+	Group string // 套接字文件的所属组，组名或 GID，为空时不修改
+	Rules []AccessRule
+}
+
+// AccessRule 允许指定的用户或组执行的操作
+//
+// Actions 可选值：status、start、stop、restart、reload、run、rm、signal、attach、shutdown，
+// "*" 表示全部操作；查看日志使用 status 权限
+//
+// start 只能启动已经注册的项目；reload 和 run 会读取客户端指定目录的配置或运行客户端指定的命令，
+// attach 可以向进程的终端输入任意内容，三者都与守护进程的运行用户（配置了 user 时为任意用户）
+// 拥有相同的权限，只应授予可信的用户
+type AccessRule struct {
+	Users   []string // 用户名或 UID
+	Groups  []string // 组名或 GID，匹配对端的主组和附加组
+	Actions []string
+}
+
+// HTTP 守护进程内置 HTTP/JSON API 的配置
+//
+// Listen 支持两种格式：
+//   - unix:/path/to/http.sock：监听 Unix 套接字
+//   - 127.0.0.1:9527：监听本机 TCP 端口，只允许回环地址；无法按 Access.Rules 检查权限，配置了规则时不会启动。
+//     不带令牌的请求只读，控制操作需要提交 Remote.Tokens 中的令牌
+type HTTP struct {
+	Enabled   bool
+	Listen    string
+	Dashboard bool // 是否在 HTTP 服务中提供网页控制台
 }
 
 type Log struct {
@@ -51,13 +141,57 @@ func setDefault() {
 		"maxAge":       7,
 		"maxBackups":   7,
 	})
+	viper.SetDefault("http", map[string]any{
+		"enabled":   false,
+		"listen":    "unix:" + constants.DaemonHTTPSockFilePath,
+		"dashboard": false,
+	})
+	viper.SetDefault("remote", map[string]any{
+		"enabled": false,
+		"listen":  ":" + constants.DefaultRemotePort,
+	})
+	viper.SetDefault("cgroup", map[string]any{
+		"enabled": false,
+		"mount":   "",
+		"parent":  constants.DefaultDaemonName,
+	})
+	viper.SetDefault("client", map[string]any{
+		"host":       "",
+		"token":      "",
+		"caFile":     "",
+		"certFile":   "",
+		"keyFile":    "",
+		"serverName": "",
+	})
+
+	_ = viper.BindEnv("client.host", "SPM_HOST")
+	_ = viper.BindEnv("client.token", "SPM_TOKEN")
 }
 
 func GetConfig() *Config {
 	return config
 }
 
-func SetConfig(configFile string) {
+// ConfigError 表示配置文件或运行时目录的错误
+//
+// 守护进程中加载配置失败时不能直接退出，而是把 ConfigError 返回给客户端，
+// 避免一个项目的错误配置影响其他项目的进程
+type ConfigError struct {
+	Path string // 出错的文件或目录
+	Op   string // 出错的操作，例如 read、parse、mkdir
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("config %s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// SetConfig 加载全局配置文件，失败时返回 *ConfigError 并保留之前的配置
+func SetConfig(configFile string) error {
 	configViperMutex.Lock()
 	defer configViperMutex.Unlock()
 
@@ -72,7 +206,7 @@ func SetConfig(configFile string) {
 		viper.AddConfigPath("../etc")
 		viper.AddConfigPath(constants.SpmHome)
 	} else if err != nil {
-		log.Fatal(err)
+		return &ConfigError{Path: configFile, Op: "stat", Err: err}
 	} else {
 		viper.SetConfigFile(configFile)
 	}
@@ -85,25 +219,31 @@ func SetConfig(configFile string) {
 
 	err = viper.ReadInConfig()
 	if err != nil && !errors.As(err, &viper.ConfigFileNotFoundError{}) {
-		log.Fatalf("Error getting config file, %v", err)
+		return &ConfigError{Path: viper.ConfigFileUsed(), Op: "read", Err: err}
 	}
 
-	err = viper.Unmarshal(&config)
+	var cfg *Config
+	err = viper.Unmarshal(&cfg)
 	if err != nil {
-		fmt.Println("Unable to decode into struct, ", err)
+		return &ConfigError{Path: viper.ConfigFileUsed(), Op: "parse", Err: err}
 	}
+
+	config = cfg
+
+	return nil
 }
 
-func GetRuntimeDir(cwd string) string {
+// GetRuntimeDir 返回 cwd 下的 tmp 运行时目录，不存在时自动创建
+func GetRuntimeDir(cwd string) (string, error) {
 	abs, err := filepath.Abs(cwd)
 	if err != nil {
-		log.Fatal(err)
+		return "", &ConfigError{Path: cwd, Op: "abs", Err: err}
 	}
 
 	info, err := os.Stat(abs)
 	if err == nil {
 		if !info.IsDir() {
-			log.Fatal(os.PathError{Op: "dirname", Path: cwd, Err: os.ErrInvalid})
+			return "", &ConfigError{Path: cwd, Op: "dirname", Err: os.ErrInvalid}
 		}
 	}
 
@@ -111,15 +251,15 @@ func GetRuntimeDir(cwd string) string {
 	info, err = os.Stat(tmp)
 	if err == nil {
 		if info.IsDir() {
-			return tmp
+			return tmp, nil
 		} else {
-			log.Fatal(os.PathError{Op: "mkdir", Path: tmp, Err: os.ErrExist})
+			return "", &ConfigError{Path: tmp, Op: "mkdir", Err: os.ErrExist}
 		}
 	} else {
 		if err := os.MkdirAll(tmp, 0755); err != nil {
-			log.Fatalf("create directory %q error: %v", tmp, err)
+			return "", &ConfigError{Path: tmp, Op: "mkdir", Err: err}
 		}
 	}
 
-	return tmp
+	return tmp, nil
 }
@@ -13,3 +13,6 @@ var WorkDirFlag string
 var ProcfileFlag string
 
 var ForegroundFlag bool
+
+// HostFlag 远程守护进程的地址，覆盖 spm.yml 的 client.host 和环境变量 SPM_HOST
+var HostFlag string
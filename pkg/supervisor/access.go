@@ -0,0 +1,346 @@
+// Package supervisor 提供控制套接字的访问控制功能
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"spm/pkg/config"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// unknownUID 无法获取对端凭据时使用的 UID，不会匹配任何用户
+const unknownUID = ^uint32(0)
+
+// aclActions 访问控制规则中可以使用的操作名称
+var aclActions = []string{"status", "start", "stop", "restart", "reload", "run", "rm", "signal", "attach", "shutdown"}
+
+// PermissionError 对端用户没有执行操作的权限
+//
+// 远程连接按令牌检查权限，此时 Peer 为令牌的描述，UID 不使用
+type PermissionError struct {
+	UID    uint32
+	Peer   string
+	Action string
+}
+
+func (e *PermissionError) Error() string {
+	peer := e.Peer
+	if peer == "" {
+		name := strconv.FormatUint(uint64(e.UID), 10)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		peer = "user " + name
+	}
+
+	return fmt.Sprintf("permission denied: %s is not allowed to %s", peer, e.Action)
+}
+
+// peerCred 通过 SO_PEERCRED 获取的对端进程凭据
+type peerCred struct {
+	pid int32
+	uid uint32
+	gid uint32
+}
+
+// getPeerCred 读取 Unix 套接字连接对端的 PID、UID 和 GID
+func getPeerCred(conn net.Conn) (*peerCred, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("not a unix socket connection")
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var ucred *unix.Ucred
+	var credErr error
+
+	err = raw.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+
+	return &peerCred{pid: ucred.Pid, uid: ucred.Uid, gid: ucred.Gid}, nil
+}
+
+// aclRule 解析后的访问控制规则
+type aclRule struct {
+	uids    []uint32
+	gids    []uint32
+	actions []string
+}
+
+// accessControl 根据对端凭据判断是否允许执行操作
+type accessControl struct {
+	ownerUID uint32
+	rules    []*aclRule
+}
+
+// newAccessControl 解析 spm.yml 中的访问控制规则
+//
+// 无法解析的用户、组和未知的操作名称会记录警告并忽略，不影响其他规则
+func newAccessControl(cfg config.Access, log *zap.SugaredLogger) *accessControl {
+	ac := &accessControl{ownerUID: uint32(os.Geteuid())}
+
+	for i, r := range cfg.Rules {
+		rule := &aclRule{}
+
+		for _, name := range r.Users {
+			uid, err := lookupUID(name)
+			if err != nil {
+				log.Warnf("access.rules[%d]: %v", i, err)
+				continue
+			}
+			rule.uids = append(rule.uids, uid)
+		}
+
+		for _, name := range r.Groups {
+			gid, err := lookupGID(name)
+			if err != nil {
+				log.Warnf("access.rules[%d]: %v", i, err)
+				continue
+			}
+			rule.gids = append(rule.gids, gid)
+		}
+
+		for _, action := range r.Actions {
+			if action != "*" && !slices.Contains(aclActions, action) {
+				log.Warnf("access.rules[%d]: unknown action %q", i, action)
+				continue
+			}
+			rule.actions = append(rule.actions, action)
+		}
+
+		ac.rules = append(ac.rules, rule)
+	}
+
+	return ac
+}
+
+// isOwner 判断对端是否为守护进程的运行用户或 root，这些用户不受访问规则限制
+func (ac *accessControl) isOwner(cred *peerCred) bool {
+	return cred != nil && (cred.uid == 0 || cred.uid == ac.ownerUID)
+}
+
+// check 判断对端是否可以执行操作，不允许时返回 *PermissionError
+//
+// cred 为 nil 表示无法确认对端身份（例如连接不是 Unix 套接字），拒绝所有操作
+func (ac *accessControl) check(cred *peerCred, action string) error {
+	if cred == nil {
+		return &PermissionError{UID: unknownUID, Action: action}
+	}
+	if ac.isOwner(cred) {
+		return nil
+	}
+
+	var gids []uint32
+	for _, rule := range ac.rules {
+		if !slices.Contains(rule.actions, "*") && !slices.Contains(rule.actions, action) {
+			continue
+		}
+
+		if slices.Contains(rule.uids, cred.uid) {
+			return nil
+		}
+
+		if len(rule.gids) > 0 {
+			if gids == nil {
+				gids = peerGroups(cred)
+			}
+			for _, gid := range gids {
+				if slices.Contains(rule.gids, gid) {
+					return nil
+				}
+			}
+		}
+	}
+
+	return &PermissionError{UID: cred.uid, Action: action}
+}
+
+// peerGroups 返回对端的主组和附加组，附加组从系统的组数据库中查询
+func peerGroups(cred *peerCred) []uint32 {
+	gids := []uint32{cred.gid}
+
+	u, err := user.LookupId(strconv.FormatUint(uint64(cred.uid), 10))
+	if err != nil {
+		return gids
+	}
+
+	groups, err := u.GroupIds()
+	if err != nil {
+		return gids
+	}
+
+	for _, g := range groups {
+		if gid, err := strconv.ParseUint(g, 10, 32); err == nil {
+			gids = append(gids, uint32(gid))
+		}
+	}
+
+	return gids
+}
+
+// aclAction 返回控制操作在访问控制规则中的名称
+func aclAction(action ActionCtl) string {
+	switch action {
+	case ActionKill:
+		return "shutdown"
+	case ActionLog:
+		// 与 HTTP 接口一致，查看日志使用 status 权限
+		return "status"
+	}
+
+	return action.String()
+}
+
+// lookupUID 解析用户名或数字形式的 UID
+func lookupUID(name string) (uint32, error) {
+	if uid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(uid), nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	return uint32(uid), err
+}
+
+// lookupGID 解析组名或数字形式的 GID
+func lookupGID(name string) (uint32, error) {
+	if gid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(gid), nil
+	}
+
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	return uint32(gid), err
+}
+
+// listenUnix 监听 Unix 套接字，按 access 配置设置好权限之后才出现在 path 上
+//
+// 先在 path 所在目录下新建的 0700 临时目录中监听并设置权限、所有者和所属组，再移动到 path，
+// 避免套接字按 umask 的权限创建后、修改权限之前被其他用户连接。
+// path 已经存在时与 net.Listen 一样返回错误，不会替换其他守护进程的套接字；
+// 关闭监听时不会删除 path，由调用方删除
+func listenUnix(path string, cfg config.Access) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".spm-sock-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	tmp := filepath.Join(dir, filepath.Base(path))
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := applySocketPerm(tmp, cfg); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+
+	if err := unix.Renameat2(unix.AT_FDCWD, tmp, unix.AT_FDCWD, path, unix.RENAME_NOREPLACE); err != nil {
+		_ = ln.Close()
+		return nil, &net.OpError{Op: "listen", Net: "unix", Addr: &net.UnixAddr{Name: path, Net: "unix"}, Err: os.NewSyscallError("rename", err)}
+	}
+
+	return ln, nil
+}
+
+// applySocketPerm 按 access 配置设置套接字文件的权限、所有者和所属组
+func applySocketPerm(path string, cfg config.Access) error {
+	if cfg.Mode != "" {
+		mode, err := strconv.ParseUint(cfg.Mode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return &config.ConfigError{Path: "access.mode", Op: "parse", Err: fmt.Errorf("invalid file mode %q", cfg.Mode)}
+		}
+
+		if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Owner == "" && cfg.Group == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+
+	if cfg.Owner != "" {
+		id, err := lookupUID(cfg.Owner)
+		if err != nil {
+			return &config.ConfigError{Path: "access.owner", Op: "lookup", Err: err}
+		}
+		uid = int(id)
+	}
+
+	if cfg.Group != "" {
+		id, err := lookupGID(cfg.Group)
+		if err != nil {
+			return &config.ConfigError{Path: "access.group", Op: "lookup", Err: err}
+		}
+		gid = int(id)
+	}
+
+	return os.Chown(path, uid, gid)
+}
+
+// peerCredKey HTTP 请求上下文中保存对端凭据的键
+type peerCredKey struct{}
+
+// withPeerCred 在 HTTP 连接的上下文中保存对端凭据，非 Unix 套接字连接不保存
+func withPeerCred(ctx context.Context, conn net.Conn) context.Context {
+	if _, ok := conn.(*net.UnixConn); !ok {
+		return ctx
+	}
+
+	cred, err := getPeerCred(conn)
+	if err != nil {
+		// 无法确认对端身份时按未知用户处理，所有操作都会被拒绝
+		cred = &peerCred{pid: -1, uid: unknownUID, gid: unknownUID}
+	}
+
+	return context.WithValue(ctx, peerCredKey{}, cred)
+}
+
+// peerCredFrom 从 HTTP 请求上下文中取出对端凭据
+func peerCredFrom(ctx context.Context) *peerCred {
+	cred, _ := ctx.Value(peerCredKey{}).(*peerCred)
+	return cred
+}
+
+// isPermissionError 判断错误是否为权限不足
+func isPermissionError(err error) bool {
+	var permErr *PermissionError
+	return errors.As(err, &permErr)
+}
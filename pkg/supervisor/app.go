@@ -15,7 +15,7 @@ import (
 // 返回：
 //
 //	*Project: 项目实例
-//	[]*Process: 新增的进程列表（仅 force=false 时返回）
+//	[]*ProcChange: 每个进程的变更结果（仅 force=false 时返回）
 //
 // 工作模式：
 //
@@ -25,15 +25,16 @@ import (
 //	  - 初始化进程状态为 false（未运行）
 //
 //	force = false（更新模式）：
-//	  - 对比新旧进程列表
-//	  - 删除不再存在且未运行的进程
-//	  - 添加新增的进程
-//	  - 返回新增进程列表
+//	  - 通过 planReload 计算新旧配置的差异
+//	  - 新增的进程注册到进程表，项目运行中时一并启动
+//	  - 配置变化的进程更新配置，运行中的进程会被重启
+//	  - 从 Procfile 中移除的进程会被停止并注销
+//	  - 配置未变化的进程保持原样
 //
 // 注意事项：
-//  1. 线程安全：使用 RWMutex 保护
+//  1. 线程安全：使用 RWMutex 保护，更新模式下进程的停止和启动在释放锁之后执行
 //  2. 进程命名格式：appName::processName
-//  3. 不会自动启动进程，仅注册
+//  3. 注册模式不会自动启动进程
 //
 // 示例：
 //
@@ -41,71 +42,128 @@ import (
 //	proj, _ := sv.UpdateApp(true, procOpts)
 //
 //	// 更新现有项目
-//	_, newProcs := sv.UpdateApp(false, procOpts)
-//	fmt.Printf("新增了 %d 个进程\n", len(newProcs))
+//	_, changes := sv.UpdateApp(false, procOpts)
+//	for _, c := range changes {
+//	    fmt.Printf("%s %s\n", c.Name, c.Change)
+//	}
 //
 // 创建时间: 2025-12-06
 func (sv *Supervisor) UpdateApp(
 	force bool,
 	procOpts *ProcfileOption,
-) (*Project, []*Process) {
+) (*Project, []*ProcChange) {
+	if !force {
+		return sv.updateApp(procOpts)
+	}
+
 	sv.mu.Lock()
 	defer sv.mu.Unlock()
 
+	if oldProj := sv.projectTable.Get(procOpts.AppName); oldProj != nil {
+		return oldProj, nil
+	}
+
+	return sv.registerApp(procOpts), nil
+}
+
+// registerApp 注册新项目及其进程，没有进程或工作目录时返回 nil，调用方需持有 sv.mu
+func (sv *Supervisor) registerApp(procOpts *ProcfileOption) *Project {
+	if len(procOpts.Processes) == 0 || procOpts.WorkDir == "" {
+		return nil
+	}
+
 	newProj := CreateProject(procOpts)
-	oldProj := sv.projectTable.Get(procOpts.AppName)
-	if force {
-		if oldProj == nil {
-			if len(procOpts.Processes) == 0 || procOpts.WorkDir == "" {
-				return nil, nil
-			}
-
-			_ = sv.projectTable.Set(procOpts.AppName, newProj)
-
-			for name, opt := range procOpts.Processes {
-				fullName := fmt.Sprintf("%s::%s", procOpts.AppName, name)
-				proc := NewProcess(fullName, opt)
-				proc.SetPidPath()
-
-				sv.procTable.Add(fullName, proc)
-				newProj.SetState(name, false)
-			}
-
-			return newProj, nil
-		}
-	} else {
-		if oldProj != nil {
-			// 记录新增的进程信息
-			pList := make([]*Process, 0)
-			oldProcList := oldProj.GetProcNames()
-			newProcList := newProj.GetProcNames()
-
-			for _, name := range oldProcList {
-				if !newProj.IsExist(name) && !oldProj.GetState(name) {
-					fullName := fmt.Sprintf("%s::%s", oldProj.Name, name)
-					_ = sv.procTable.Del(fullName)
-				}
-			}
-
-			for _, name := range newProcList {
-				fullName := fmt.Sprintf("%s::%s", newProj.Name, name)
-				if exist := sv.procTable.Get(fullName); exist != nil {
-					continue
-				}
-
-				opt := procOpts.Processes[name]
-				proc := NewProcess(fullName, opt)
-				proc.SetPidPath()
-
-				sv.procTable.Add(fullName, proc)
-				oldProj.SetState(name, false)
-
-				pList = append(pList, proc)
-			}
-
-			return oldProj, pList
-		}
+	_ = sv.projectTable.Set(procOpts.AppName, newProj)
+
+	for name, opt := range procOpts.Processes {
+		fullName := fmt.Sprintf("%s::%s", procOpts.AppName, name)
+		proc := NewProcess(fullName, opt)
+		proc.SetPidPath()
+
+		sv.procTable.Add(fullName, proc)
+		newProj.SetState(name, false)
+	}
+
+	return newProj
+}
+
+// updateApp 按变更计划更新已注册的项目，项目不存在时返回 nil
+//
+// 持有 sv.mu 时只计算计划并更新进程表，进程的停止和启动在释放锁之后执行：
+// 每个进程的 Stop 最多等待 stopTimeout，期间其他控制请求、HTTP 接口和 watchdog 不会被阻塞
+func (sv *Supervisor) updateApp(procOpts *ProcfileOption) (*Project, []*ProcChange) {
+	sv.mu.Lock()
+	proj := sv.projectTable.Get(procOpts.AppName)
+	if proj == nil {
+		sv.mu.Unlock()
+		return nil, nil
+	}
+
+	changes := sv.planReload(proj, procOpts)
+	for _, c := range changes {
+		sv.registerChange(proj, procOpts, c)
 	}
+	sv.mu.Unlock()
 
-	return oldProj, nil
+	for _, c := range changes {
+		sv.applyChange(proj, procOpts, c)
+	}
+
+	return proj, changes
+}
+
+// registerChange 按变更计划更新进程表，调用方需持有 sv.mu
+func (sv *Supervisor) registerChange(proj *Project, procOpts *ProcfileOption, c *ProcChange) {
+	name := c.Name[len(proj.Name)+2:]
+
+	switch c.Action {
+	case ReloadStart, ReloadRegister:
+		proc := NewProcess(c.Name, procOpts.Processes[name])
+		proc.SetPidPath()
+
+		sv.procTable.Add(c.Name, proc)
+		proj.SetState(name, false)
+		c.proc = proc
+	case ReloadUpdate:
+		// 进程没有在运行，直接更新配置
+		proc := sv.procTable.Get(c.Name)
+		proc.UpdateOptions(procOpts.Processes[name])
+		proc.SetPidPath()
+		c.proc = proc
+	case ReloadRestart:
+		c.proc = sv.procTable.Get(c.Name)
+	case ReloadStop, ReloadRemove:
+		c.proc = sv.procTable.Get(c.Name)
+
+		_ = sv.procTable.Del(c.Name)
+		proj.Remove(name)
+	}
+}
+
+// applyChange 停止或启动 registerChange 处理过的进程，不持有 sv.mu
+func (sv *Supervisor) applyChange(proj *Project, procOpts *ProcfileOption, c *ProcChange) {
+	name := c.Name[len(proj.Name)+2:]
+
+	switch c.Action {
+	case ReloadStart:
+		proj.SetState(name, c.proc.Start())
+	case ReloadRestart:
+		// 先用旧配置停止，保证旧的 PID 文件被正确清理
+		c.proc.Stop()
+
+		c.proc.UpdateOptions(procOpts.Processes[name])
+		c.proc.SetPidPath()
+
+		proj.SetState(name, c.proc.Start())
+	case ReloadStop:
+		c.proc.Stop()
+	case ReloadNone:
+		c.proc = sv.procTable.Get(c.Name)
+	}
+
+	c.fill(c.proc)
+
+	if c.Action != ReloadNone && c.proc != nil {
+		publishEvent(c.proc, EventReload, 0, string(c.Action))
+	}
 }
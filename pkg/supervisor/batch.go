@@ -68,25 +68,19 @@ func (sv *Supervisor) BatchDo(toDo ActionCtl, opt *ProcfileOption, procs []strin
 		completed := doMany("*")
 
 		for _, p := range completed {
-			pInfo = append(pInfo, &ProcInfo{
-				Pid:     p.Pid,
-				Name:    p.FullName,
-				StartAt: p.StartAt.UnixMilli(),
-				StopAt:  p.StopAt.UnixMilli(),
-				Status:  p.State,
-			})
+			pInfo = append(pInfo, newProcInfo(p, ""))
 		}
 	} else {
 		for _, name := range procs {
 			p := doFn(name)
 			if p != nil {
-				pInfo = append(pInfo, &ProcInfo{
-					Pid:     p.Pid,
-					Name:    p.FullName,
-					StartAt: p.StartAt.UnixMilli(),
-					StopAt:  p.StopAt.UnixMilli(),
-					Status:  p.State,
-				})
+				// 不存在的进程返回共享的 notFoundProc，使用请求中的进程名
+				fullName := p.FullName
+				if p.State == processNotfound {
+					fullName = name
+				}
+
+				pInfo = append(pInfo, newProcInfo(p, fullName))
 			}
 		}
 	}
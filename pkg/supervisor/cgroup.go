@@ -0,0 +1,420 @@
+// Package supervisor 提供基于 cgroup v2 的资源控制、进程树清理和资源统计功能
+package supervisor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"spm/pkg/config"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// cgroupControllers spm 需要在子树中启用的控制器
+var cgroupControllers = []string{"cpu", "io", "memory", "pids"}
+
+// cpuPeriod cpu.max 使用的默认周期（微秒）
+const cpuPeriod = 100000
+
+// cgroupParent 所有项目 cgroup 的父目录，为空表示没有启用 cgroup
+//
+// 由 initCgroups 在守护进程启动时设置，之后只读
+var cgroupParent string
+
+// CgroupOption cgroup v2 资源限制，可以在 Procfile.options 的项目级和进程级设置
+//
+// 项目级的限制作用于整个项目的 cgroup，进程级的限制作用于进程的叶子 cgroup
+type CgroupOption struct {
+	MemoryMax string // memory.max，例如 512M，max 表示不限制
+	CPUMax    string // cpu.max，CPU 核数（1.5）、单核百分比（50%）或 "配额 周期"（微秒），max 表示不限制
+	PidsMax   string // pids.max，max 表示不限制
+	IOWeight  int    // io.weight，1-10000，0 表示不设置
+}
+
+// IsEmpty 判断是否没有设置任何限制
+func (c CgroupOption) IsEmpty() bool {
+	return c == CgroupOption{}
+}
+
+// parseCgroupLimits 把 CgroupOption 转换为 cgroup 接口文件名和要写入的值
+//
+// 返回：
+//
+//	map[string]string: 例如 {"memory.max": "536870912", "cpu.max": "50000 100000"}，没有限制时为 nil
+//	error: 限制值无效
+func parseCgroupLimits(opt CgroupOption) (map[string]string, error) {
+	if opt.IsEmpty() {
+		return nil, nil
+	}
+
+	limits := make(map[string]string)
+
+	if v := strings.TrimSpace(opt.MemoryMax); v != "" {
+		if strings.EqualFold(v, "max") {
+			limits["memory.max"] = "max"
+		} else {
+			n, err := parseSize(v)
+			if err != nil {
+				return nil, fmt.Errorf("memoryMax: %w", err)
+			}
+			limits["memory.max"] = strconv.FormatUint(n, 10)
+		}
+	}
+
+	if v := strings.TrimSpace(opt.CPUMax); v != "" {
+		cpuMax, err := parseCPUMax(v)
+		if err != nil {
+			return nil, fmt.Errorf("cpuMax: %w", err)
+		}
+		limits["cpu.max"] = cpuMax
+	}
+
+	if v := strings.TrimSpace(opt.PidsMax); v != "" {
+		if strings.EqualFold(v, "max") {
+			limits["pids.max"] = "max"
+		} else {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("pidsMax: invalid value %q", v)
+			}
+			limits["pids.max"] = strconv.FormatUint(n, 10)
+		}
+	}
+
+	if opt.IOWeight != 0 {
+		if opt.IOWeight < 1 || opt.IOWeight > 10000 {
+			return nil, fmt.Errorf("ioWeight: %d is out of range 1-10000", opt.IOWeight)
+		}
+		limits["io.weight"] = fmt.Sprintf("default %d", opt.IOWeight)
+	}
+
+	return limits, nil
+}
+
+// parseCPUMax 解析 cpuMax，返回 cpu.max 的 "配额 周期" 格式
+func parseCPUMax(v string) (string, error) {
+	if strings.EqualFold(v, "max") {
+		return fmt.Sprintf("max %d", cpuPeriod), nil
+	}
+
+	// "配额 周期" 原始格式
+	if quota, period, found := strings.Cut(v, " "); found {
+		q, err1 := strconv.ParseUint(strings.TrimSpace(quota), 10, 64)
+		p, err2 := strconv.ParseUint(strings.TrimSpace(period), 10, 64)
+		if err1 != nil || err2 != nil || q < 1000 || p < 1000 || p > 1000000 {
+			return "", fmt.Errorf("invalid value %q, expected \"quota period\" in microseconds", v)
+		}
+		return fmt.Sprintf("%d %d", q, p), nil
+	}
+
+	var cpus float64
+	var err error
+	if percent, ok := strings.CutSuffix(v, "%"); ok {
+		cpus, err = strconv.ParseFloat(percent, 64)
+		cpus /= 100
+	} else {
+		cpus, err = strconv.ParseFloat(v, 64)
+	}
+	if err != nil || cpus <= 0 {
+		return "", fmt.Errorf("invalid value %q", v)
+	}
+
+	// 内核要求配额不小于 1ms
+	quota := max(int64(cpus*cpuPeriod), 1000)
+
+	return fmt.Sprintf("%d %d", quota, cpuPeriod), nil
+}
+
+// cgroupLimitsString 把限制格式化为 "cpu.max=50000 100000, memory.max=536870912" 的形式，用于 reload 时比较
+func cgroupLimitsString(limits map[string]string) string {
+	parts := make([]string, 0, len(limits))
+	for _, key := range slices.Sorted(maps.Keys(limits)) {
+		parts = append(parts, key+"="+limits[key])
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// initCgroups 按 spm.yml 的 cgroup 配置创建父目录，并在父目录及其祖先中启用控制器
+//
+// 未启用时直接返回；无法启用某个控制器时只记录警告，使用该控制器的限制会在进程启动时报错
+func initCgroups(cfg config.Cgroup, log *zap.SugaredLogger) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	mount := cfg.Mount
+	if mount == "" {
+		var err error
+		if mount, err = findCgroup2Mount(); err != nil {
+			return err
+		}
+	}
+
+	parent := cfg.Parent
+	if !filepath.IsAbs(parent) {
+		parent = filepath.Join(mount, parent)
+	}
+	parent = filepath.Clean(parent)
+
+	rel, err := filepath.Rel(mount, parent)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return &config.ConfigError{Path: "cgroup.parent", Op: "validate", Err: fmt.Errorf("%s is not under the cgroup2 mount %s", parent, mount)}
+	}
+
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	// 从挂载点开始逐级启用控制器，子目录只能使用父目录已启用的控制器
+	dir := mount
+	for _, part := range append([]string{"."}, strings.Split(rel, string(filepath.Separator))...) {
+		dir = filepath.Join(dir, part)
+		if err := enableControllers(dir); err != nil {
+			log.Warnf("cgroup %s: %v", dir, err)
+		}
+	}
+
+	cgroupParent = parent
+	log.Infof("Processes are placed in cgroup %s", parent)
+
+	return nil
+}
+
+// findCgroup2Mount 从 /proc/self/mountinfo 中查找 cgroup2 的挂载点
+func findCgroup2Mount() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式：ID 父ID 设备号 根 挂载点 选项 [可选字段...] - 文件系统类型 来源 超级块选项
+		fields := strings.Fields(scanner.Text())
+		sep := slices.Index(fields, "-")
+		if sep > 4 && sep+1 < len(fields) && fields[sep+1] == "cgroup2" {
+			return fields[4], nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("cgroup2 is not mounted")
+}
+
+// enableControllers 在 cgroup 的 subtree_control 中启用 cgroupControllers 中可用的控制器
+func enableControllers(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+
+	available := strings.Fields(string(data))
+	enable := make([]string, 0, len(cgroupControllers))
+	for _, c := range cgroupControllers {
+		if slices.Contains(available, c) {
+			enable = append(enable, "+"+c)
+		}
+	}
+
+	if len(enable) == 0 {
+		return nil
+	}
+
+	return writeCgroupFile(filepath.Join(dir, "cgroup.subtree_control"), strings.Join(enable, " "))
+}
+
+// writeCgroupFile 写入 cgroup 接口文件
+//
+// 不能使用 os.WriteFile：cgroupfs 不允许创建文件，带 O_CREATE 打开不存在的接口文件时返回 EACCES，
+// 无法和控制器未启用（ENOENT）区分
+func writeCgroupFile(path, value string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(value); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeCgroupLimits 把资源限制写入 cgroup 的接口文件
+func writeCgroupLimits(dir string, limits map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(limits)) {
+		err := writeCgroupFile(filepath.Join(dir, key), limits[key])
+		if errors.Is(err, os.ErrNotExist) {
+			controller, _, _ := strings.Cut(key, ".")
+			return fmt.Errorf("cannot set %s: controller %s is not enabled in %s", key, controller, filepath.Dir(dir))
+		}
+		if err != nil {
+			return fmt.Errorf("cannot set %s in %s: %w", key, dir, err)
+		}
+	}
+
+	return nil
+}
+
+// cgroupPath 返回进程的叶子 cgroup 目录，没有启用 cgroup 时返回空字符串
+func (p *Process) cgroupPath() string {
+	if cgroupParent == "" {
+		return ""
+	}
+
+	appName := strings.Split(p.FullName, "::")[0]
+
+	// 定时任务的额外实例使用单独的叶子 cgroup，进程名中不能包含 "."，不会与其他进程冲突
+	if p.cloneID > 0 {
+		return filepath.Join(cgroupParent, appName, fmt.Sprintf("%s.%d", p.Name, p.cloneID))
+	}
+
+	return filepath.Join(cgroupParent, appName, p.Name)
+}
+
+// setupCgroup 创建项目和进程的 cgroup，写入资源限制，返回叶子 cgroup 的目录文件描述符
+//
+// 没有启用 cgroup 时返回 -1。叶子 cgroup 中残留上一次运行的进程时先全部终止，
+// 调用方在进程启动后需要关闭返回的文件描述符
+func (p *Process) setupCgroup() (int, error) {
+	leaf := p.cgroupPath()
+	if leaf == "" {
+		if len(p.Options.cgroup) > 0 || len(p.Options.projectCgroup) > 0 {
+			p.logger.Warn("cgroup limits are ignored because cgroup is not enabled in spm.yml")
+		}
+		return -1, nil
+	}
+
+	projDir := filepath.Dir(leaf)
+	if err := os.MkdirAll(leaf, 0755); err != nil {
+		return -1, err
+	}
+
+	if err := enableControllers(projDir); err != nil {
+		p.logger.Warnf("cgroup %s: %v", projDir, err)
+	}
+
+	if err := writeCgroupLimits(projDir, p.Options.projectCgroup); err != nil {
+		return -1, err
+	}
+
+	if err := writeCgroupLimits(leaf, p.Options.cgroup); err != nil {
+		return -1, err
+	}
+
+	if cgroupPopulated(leaf) {
+		p.logger.Warnf("Killing processes left in cgroup %s", leaf)
+		killCgroup(leaf)
+	}
+
+	return unix.Open(leaf, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+}
+
+// cleanupCgroup 终止叶子 cgroup 中残留的所有进程，然后删除叶子 cgroup 和空的项目 cgroup
+//
+// 用于停止进程后清理主进程派生的所有子进程
+func (p *Process) cleanupCgroup() {
+	leaf := p.cgroupPath()
+	if leaf == "" {
+		return
+	}
+
+	if cgroupPopulated(leaf) {
+		p.logger.Infof("Killing processes left in cgroup %s", leaf)
+		killCgroup(leaf)
+	}
+
+	if err := os.Remove(leaf); err != nil && !errors.Is(err, os.ErrNotExist) {
+		p.logger.Warn(err)
+		return
+	}
+
+	// 项目中还有其他进程时删除失败，忽略错误
+	_ = os.Remove(filepath.Dir(leaf))
+}
+
+// cgroupPopulated 判断 cgroup 中是否还有进程
+func cgroupPopulated(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.events"))
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(string(data), "populated 1")
+}
+
+// killCgroup 向 cgroup 中的所有进程发送 SIGKILL，并等待它们退出
+//
+// 优先使用 cgroup.kill（Linux 5.14+），不支持时逐个终止 cgroup.procs 中的进程
+func killCgroup(dir string) {
+	err := writeCgroupFile(filepath.Join(dir, "cgroup.kill"), "1")
+	if err != nil {
+		for _, pid := range cgroupProcs(dir) {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cgroupPopulated(dir) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// cgroupProcs 返回 cgroup 中所有进程的 PID
+func cgroupProcs(dir string) []int {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return nil
+	}
+
+	pids := make([]int, 0)
+	for _, line := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(line); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids
+}
+
+// readCgroupUsage 读取 cgroup 的 CPU 时间、内存占用和进程数，用于替换只统计主进程的数据
+//
+// memory.current 需要启用 memory 控制器，读取失败时保留 usage 中原有的值
+func readCgroupUsage(dir string, usage *ProcUsage) {
+	if data, err := os.ReadFile(filepath.Join(dir, "cpu.stat")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if v, ok := strings.CutPrefix(line, "usage_usec "); ok {
+				if usec, err := strconv.ParseUint(v, 10, 64); err == nil {
+					usage.CPUSeconds = float64(usec) / 1e6
+				}
+				break
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "memory.current")); err == nil {
+		if n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			usage.RSSBytes = n
+		}
+	}
+
+	usage.Procs = len(cgroupProcs(dir))
+}
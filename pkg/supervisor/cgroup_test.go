@@ -0,0 +1,70 @@
+package supervisor
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseCPUMax(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "max", want: "max 100000"},
+		{value: "MAX", want: "max 100000"},
+		{value: "0.5", want: "50000 100000"},
+		{value: "2", want: "200000 100000"},
+		{value: "50%", want: "50000 100000"},
+		{value: "150%", want: "150000 100000"},
+		{value: "0.001", want: "1000 100000"},
+		{value: "0.0001", want: "1000 100000"},
+		{value: "20000 50000", want: "20000 50000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseCPUMax(tt.value)
+			if err != nil {
+				t.Fatalf("parseCPUMax(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseCPUMax(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCPUMaxErrors(t *testing.T) {
+	for _, value := range []string{"0", "-1", "0%", "abc", "half", "500 100000", "20000 500", "20000 2000000", "a b"} {
+		if _, err := parseCPUMax(value); err == nil {
+			t.Errorf("parseCPUMax(%q) expected error", value)
+		}
+	}
+}
+
+func TestParseCgroupLimits(t *testing.T) {
+	limits, err := parseCgroupLimits(CgroupOption{MemoryMax: "512M", CPUMax: "50%", PidsMax: "100", IOWeight: 200})
+	if err != nil {
+		t.Fatalf("parseCgroupLimits() error = %v", err)
+	}
+
+	want := map[string]string{
+		"memory.max": "536870912",
+		"cpu.max":    "50000 100000",
+		"pids.max":   "100",
+		"io.weight":  "default 200",
+	}
+	if !maps.Equal(limits, want) {
+		t.Errorf("parseCgroupLimits() = %v, want %v", limits, want)
+	}
+
+	if limits, err := parseCgroupLimits(CgroupOption{}); limits != nil || err != nil {
+		t.Errorf("parseCgroupLimits(empty) = %v, %v, want nil, nil", limits, err)
+	}
+
+	for _, opt := range []CgroupOption{{MemoryMax: "lots"}, {PidsMax: "0"}, {IOWeight: 20000}} {
+		if _, err := parseCgroupLimits(opt); err == nil {
+			t.Errorf("parseCgroupLimits(%+v) expected error", opt)
+		}
+	}
+}
@@ -0,0 +1,687 @@
+// Package supervisor 提供 Procfile 和 Procfile.options 的静态检查功能
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"spm/pkg/config"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Severity 检查问题的严重程度
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Problem 描述一条配置问题，Line 为 0 表示无法定位到具体行
+type Problem struct {
+	File     string
+	Line     int
+	Severity Severity
+	Message  string
+}
+
+func (p *Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Severity, p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Severity, p.Message)
+}
+
+// optionKind 配置项期望的 YAML 类型
+type optionKind int
+
+const (
+	kindString optionKind = iota
+	kindInt
+	kindBool
+	kindStringList
+	kindStringMap
+	kindMapping
+	kindProcesses
+)
+
+// projectOptionSchema 项目级配置项，键名按 viper 的规则不区分大小写
+var projectOptionSchema = map[string]optionKind{
+	"appname":   kindString,
+	"workdir":   kindString,
+	"procfile":  kindString,
+	"envfile":   kindStringList,
+	"env":       kindStringMap,
+	"processes": kindProcesses,
+	"memorymax": kindString,
+	"cpumax":    kindString,
+	"pidsmax":   kindString,
+	"ioweight":  kindInt,
+}
+
+// processOptionSchema 进程级配置项
+var processOptionSchema = map[string]optionKind{
+	"root":        kindString,
+	"pidroot":     kindString,
+	"logroot":     kindString,
+	"stopsignal":  kindString,
+	"numprocs":    kindInt,
+	"envfile":     kindStringList,
+	"env":         kindStringMap,
+	"user":        kindString,
+	"group":       kindString,
+	"groups":      kindStringList,
+	"rlimits":     kindStringMap,
+	"memorymax":   kindString,
+	"cpumax":      kindString,
+	"pidsmax":     kindString,
+	"ioweight":    kindInt,
+	"watchdog":    kindStringMap,
+	"schedule":    kindString,
+	"overlap":     kindString,
+	"nice":        kindInt,
+	"ioniceclass": kindString,
+	"ionicelevel": kindInt,
+	"oomscoreadj": kindInt,
+	"cpuaffinity": kindString,
+	"tty":         kindBool,
+	"watch":       kindMapping,
+}
+
+var procNamePattern = regexp.MustCompile(`^[A-Za-z]+[A-Za-z0-9-_]+$`)
+
+// checker 收集检查过程中发现的问题
+type checker struct {
+	problems []*Problem
+}
+
+func (c *checker) add(file string, line int, sev Severity, format string, args ...any) {
+	c.problems = append(c.problems, &Problem{
+		File:     file,
+		Line:     line,
+		Severity: sev,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// CheckProcfile 检查项目的 Procfile 和 Procfile.options，返回发现的所有问题
+//
+// 参数：
+//
+//	workDir: 项目工作目录
+//	procfile: Procfile 路径
+//
+// 返回：
+//
+//	[]*Problem: 按文件和行号排序的问题列表，没有问题时为空
+//
+// 检查内容：
+//   - Procfile 的格式、进程名和命令是否能在 PATH 中找到
+//   - Procfile.options 中的未知键、类型错误、无效的停止信号、不存在的用户或组、无效的 rlimits、cgroup 资源限制、watchdog、watch、调度选项和 schedule
+//   - root/pidRoot/logRoot 目录和 envFile 文件是否存在
+//   - 配置了选项但不在 Procfile 中的进程
+//
+// 与 LoadProcfileOption 不同，本函数不会在遇到第一个错误时停止
+func CheckProcfile(workDir, procfile string) []*Problem {
+	c := &checker{problems: make([]*Problem, 0)}
+
+	optsFile := findOptionsFile(workDir)
+	var optsRoot *yaml.Node
+	if optsFile != "" {
+		optsRoot = c.parseYaml(optsFile)
+	}
+
+	// Procfile.options 中可以覆盖 procfile 和 workDir 的值
+	if optsRoot != nil {
+		if v := lookupKey(optsRoot, "procfile"); v != nil && v.Kind == yaml.ScalarNode && v.Value != "" {
+			procfile = v.Value
+		}
+		if v := lookupKey(optsRoot, "workdir"); v != nil && v.Kind == yaml.ScalarNode && v.Value != "" {
+			workDir = v.Value
+		}
+	}
+
+	commands := c.checkProcfile(procfile)
+
+	if optsRoot != nil {
+		c.checkOptions(optsFile, optsRoot, workDir, commands)
+	}
+
+	// 没有进程级配置的进程使用默认的 root，也需要检查命令
+	for name, cmd := range commands {
+		if !cmd.checked {
+			c.checkCommand(cmd.file, cmd.line, name, cmd.value, workDir)
+		}
+	}
+
+	sort.SliceStable(c.problems, func(i, j int) bool {
+		if c.problems[i].File != c.problems[j].File {
+			return c.problems[i].File < c.problems[j].File
+		}
+		return c.problems[i].Line < c.problems[j].Line
+	})
+
+	return c.problems
+}
+
+// HasErrors 判断问题列表中是否包含错误级别的问题
+func HasErrors(problems []*Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findOptionsFile 按 LoadProcfileOption 相同的顺序查找 Procfile.options
+func findOptionsFile(workDir string) string {
+	candidates := []string{
+		filepath.Join(workDir, "Procfile.options"),
+		"Procfile.options",
+		filepath.Join("etc", "Procfile.options"),
+		filepath.Join("..", "etc", "Procfile.options"),
+	}
+
+	for _, f := range candidates {
+		if info, err := os.Stat(f); err == nil && !info.IsDir() {
+			return f
+		}
+	}
+
+	return ""
+}
+
+func (c *checker) parseYaml(file string) *yaml.Node {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		c.add(file, 0, SeverityError, "%v", err)
+		return nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		line := 0
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			line = yamlErrorLine(err)
+		}
+		c.add(file, line, SeverityError, "invalid YAML: %v", err)
+		return nil
+	}
+
+	// 空文件
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		c.add(file, root.Line, SeverityError, "expected a mapping at the top level")
+		return nil
+	}
+
+	return root
+}
+
+// procCommand 记录 Procfile 中的一条命令及其位置
+type procCommand struct {
+	file    string
+	value   string
+	line    int
+	checked bool
+}
+
+func (c *checker) checkProcfile(procfile string) map[string]*procCommand {
+	commands := make(map[string]*procCommand)
+
+	found := len(c.problems)
+	root := c.parseYaml(procfile)
+	if root == nil {
+		if len(c.problems) == found {
+			c.add(procfile, 0, SeverityError, "no processes defined")
+		}
+		return commands
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+
+		if !procNamePattern.MatchString(key.Value) {
+			c.add(procfile, key.Line, SeverityError, "invalid process name %q, must consist of 'a-z A-Z 0-9 - _'", key.Value)
+		}
+
+		if val.Kind != yaml.ScalarNode {
+			c.add(procfile, val.Line, SeverityError, "command of process %q must be a string", key.Value)
+			continue
+		}
+
+		if strings.TrimSpace(val.Value) == "" {
+			c.add(procfile, val.Line, SeverityError, "command of process %q is empty", key.Value)
+			continue
+		}
+
+		commands[key.Value] = &procCommand{file: procfile, value: val.Value, line: val.Line}
+	}
+
+	return commands
+}
+
+func (c *checker) checkOptions(file string, root *yaml.Node, workDir string, commands map[string]*procCommand) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+
+		kind, ok := projectOptionSchema[strings.ToLower(key.Value)]
+		if !ok {
+			c.add(file, key.Line, SeverityError, "unknown option %q", key.Value)
+			continue
+		}
+
+		if !c.checkKind(file, key.Value, val, kind) {
+			continue
+		}
+
+		switch strings.ToLower(key.Value) {
+		case "workdir":
+			c.checkDir(file, val, "workDir", workDir)
+		case "procfile":
+			if val.Value != "" {
+				if _, err := os.Stat(val.Value); err != nil {
+					c.add(file, val.Line, SeverityError, "procfile %q: %v", val.Value, errors.Unwrap(err))
+				}
+			}
+		case "envfile":
+			c.checkEnvFiles(file, val, workDir)
+		case "processes":
+			c.checkProcesses(file, val, workDir, commands)
+		case "memorymax", "cpumax", "pidsmax", "ioweight":
+			c.checkCgroupOption(file, "project", key.Value, val)
+		}
+	}
+}
+
+func (c *checker) checkProcesses(file string, procs *yaml.Node, workDir string, commands map[string]*procCommand) {
+	for i := 0; i+1 < len(procs.Content); i += 2 {
+		key, val := procs.Content[i], procs.Content[i+1]
+		name := key.Value
+
+		cmd, ok := commands[name]
+		if !ok {
+			c.add(file, key.Line, SeverityWarning, "options for process %q which is not in the Procfile", name)
+		}
+
+		// 空的进程配置，例如 "web:" 后面没有内容
+		if val.Kind == yaml.ScalarNode && val.Tag == "!!null" {
+			continue
+		}
+
+		if val.Kind != yaml.MappingNode {
+			c.add(file, val.Line, SeverityError, "options of process %q must be a mapping", name)
+			continue
+		}
+
+		root := workDir
+
+		for j := 0; j+1 < len(val.Content); j += 2 {
+			optKey, optVal := val.Content[j], val.Content[j+1]
+
+			kind, known := processOptionSchema[strings.ToLower(optKey.Value)]
+			if !known {
+				c.add(file, optKey.Line, SeverityError, "unknown option %q for process %q", optKey.Value, name)
+				continue
+			}
+
+			if !c.checkKind(file, name+"."+optKey.Value, optVal, kind) {
+				continue
+			}
+
+			switch strings.ToLower(optKey.Value) {
+			case "root":
+				if optVal.Value != "" {
+					root = optVal.Value
+				}
+				c.checkDir(file, optVal, optKey.Value, workDir)
+			case "pidroot", "logroot":
+				c.checkDir(file, optVal, optKey.Value, workDir)
+			case "stopsignal":
+				if optVal.Value != "" {
+					if _, ok := sigTable[optVal.Value]; !ok {
+						c.add(file, optVal.Line, SeverityError, "invalid stopSignal %q for process %q, supported: %s",
+							optVal.Value, name, strings.Join(signalNames(), " "))
+					}
+				}
+			case "user":
+				if optVal.Value != "" {
+					if _, err := lookupUser(optVal.Value); err != nil {
+						c.add(file, optVal.Line, SeverityError, "user of process %q: %v", name, err)
+					}
+				}
+			case "group":
+				if optVal.Value != "" {
+					if _, err := lookupGroup(optVal.Value); err != nil {
+						c.add(file, optVal.Line, SeverityError, "group of process %q: %v", name, err)
+					}
+				}
+			case "groups":
+				// 与 viper 一致，字符串形式的 groups 按逗号分隔
+				groups := []*yaml.Node{optVal}
+				if optVal.Kind == yaml.SequenceNode {
+					groups = optVal.Content
+				}
+				for _, g := range groups {
+					for _, group := range strings.Split(g.Value, ",") {
+						if _, err := lookupGroup(strings.TrimSpace(group)); err != nil {
+							c.add(file, g.Line, SeverityError, "groups of process %q: %v", name, err)
+						}
+					}
+				}
+			case "rlimits":
+				for k := 0; k+1 < len(optVal.Content); k += 2 {
+					limitKey, limitVal := optVal.Content[k], optVal.Content[k+1]
+					if _, ok := rlimitResources[strings.ToLower(limitKey.Value)]; !ok {
+						c.add(file, limitKey.Line, SeverityError, "unknown rlimit %q for process %q, supported: %s",
+							limitKey.Value, name, strings.Join(rlimitNames(), " "))
+						continue
+					}
+					if _, err := parseRlimit(limitVal.Value); err != nil {
+						c.add(file, limitVal.Line, SeverityError, "rlimit %s for process %q: %v", limitKey.Value, name, err)
+					}
+				}
+			case "memorymax", "cpumax", "pidsmax", "ioweight":
+				c.checkCgroupOption(file, fmt.Sprintf("process %q", name), optKey.Value, optVal)
+			case "watchdog":
+				c.checkWatchdog(file, name, optVal)
+			case "watch":
+				c.checkWatch(file, name, optVal)
+			case "schedule":
+				if _, err := parseSchedule(optVal.Value); err != nil {
+					c.add(file, optVal.Line, SeverityError, "schedule of process %q: %v", name, err)
+				}
+			case "overlap":
+				if _, err := parseOverlap(optVal.Value); err != nil {
+					c.add(file, optVal.Line, SeverityError, "overlap of process %q: %v", name, err)
+				}
+			case "nice", "ioniceclass", "ionicelevel", "oomscoreadj", "cpuaffinity":
+				c.checkSched(file, name, optKey.Value, optVal)
+			case "numprocs":
+				n, _ := strconv.Atoi(optVal.Value)
+				if n > maxCpus {
+					c.add(file, optVal.Line, SeverityWarning, "numProcs %d for process %q exceeds CPU count, will be capped to %d", n, name, maxCpus)
+				}
+			}
+		}
+
+		// envFile 相对路径基于进程的 root，需要在 root 确定后检查
+		if envFile := lookupKey(val, "envfile"); envFile != nil {
+			c.checkEnvFiles(file, envFile, root)
+		}
+
+		if ok {
+			cmd.checked = true
+			c.checkCommand(cmd.file, cmd.line, name, cmd.value, root)
+		}
+	}
+}
+
+// checkCgroupOption 检查单个 cgroup 资源限制的值，没有在 spm.yml 中启用 cgroup 时给出警告
+func (c *checker) checkCgroupOption(file, owner, key string, val *yaml.Node) {
+	var opt CgroupOption
+	switch strings.ToLower(key) {
+	case "memorymax":
+		opt.MemoryMax = val.Value
+	case "cpumax":
+		opt.CPUMax = val.Value
+	case "pidsmax":
+		opt.PidsMax = val.Value
+	case "ioweight":
+		opt.IOWeight, _ = strconv.Atoi(val.Value)
+	}
+
+	if _, err := parseCgroupLimits(opt); err != nil {
+		c.add(file, val.Line, SeverityError, "%s: %v", owner, err)
+		return
+	}
+
+	if !config.GetConfig().Cgroup.Enabled {
+		c.add(file, val.Line, SeverityWarning, "%s of %s is ignored because cgroup is not enabled in spm.yml", key, owner)
+	}
+}
+
+// checkWatchdog 检查进程的 watchdog 配置中的键和值
+func (c *checker) checkWatchdog(file, name string, val *yaml.Node) {
+	opt := &WatchdogOption{}
+	for i := 0; i+1 < len(val.Content); i += 2 {
+		key, v := val.Content[i], val.Content[i+1]
+		switch strings.ToLower(key.Value) {
+		case "maxmemory":
+			opt.MaxMemory = v.Value
+		case "maxcpu":
+			opt.MaxCPU = v.Value
+		case "for":
+			opt.For = v.Value
+		case "restartmode":
+			opt.RestartMode = v.Value
+		default:
+			c.add(file, key.Line, SeverityError, "unknown watchdog option %q for process %q, supported: maxMemory maxCpu for restartMode", key.Value, name)
+		}
+	}
+
+	if _, err := parseWatchdog(opt); err != nil {
+		c.add(file, val.Line, SeverityError, "watchdog of process %q: %v", name, err)
+	}
+}
+
+// checkWatch 检查进程的 watch 配置中的键和值
+func (c *checker) checkWatch(file, name string, val *yaml.Node) {
+	opt := &WatchOption{}
+	for i := 0; i+1 < len(val.Content); i += 2 {
+		key, v := val.Content[i], val.Content[i+1]
+		switch strings.ToLower(key.Value) {
+		case "globs", "ignore":
+			if !c.checkKind(file, key.Value, v, kindStringList) {
+				continue
+			}
+			patterns := []string{v.Value}
+			if v.Kind == yaml.SequenceNode {
+				patterns = patterns[:0]
+				for _, item := range v.Content {
+					patterns = append(patterns, item.Value)
+				}
+			}
+			if strings.EqualFold(key.Value, "globs") {
+				opt.Globs = patterns
+			} else {
+				opt.Ignore = patterns
+			}
+		case "debounce":
+			opt.Debounce = v.Value
+		default:
+			c.add(file, key.Line, SeverityError, "unknown watch option %q for process %q, supported: globs ignore debounce", key.Value, name)
+		}
+	}
+
+	if _, err := parseWatch(opt); err != nil {
+		c.add(file, val.Line, SeverityError, "watch of process %q: %v", name, err)
+	}
+}
+
+// checkSched 检查单个调度选项的值，CPU 亲和性中的 CPU 不在本机时给出警告
+func (c *checker) checkSched(file, name, key string, val *yaml.Node) {
+	var opt SchedOption
+	n, _ := strconv.Atoi(val.Value)
+	switch strings.ToLower(key) {
+	case "nice":
+		opt.Nice = &n
+	case "ioniceclass":
+		opt.IoniceClass = val.Value
+	case "ionicelevel":
+		opt.IoniceLevel = &n
+	case "oomscoreadj":
+		opt.OomScoreAdj = &n
+	case "cpuaffinity":
+		opt.CPUAffinity = val.Value
+	}
+
+	s, err := parseSched(opt)
+	if err != nil {
+		c.add(file, val.Line, SeverityError, "process %q: %v", name, err)
+		return
+	}
+
+	if s != nil && len(s.cpus) > 0 && s.cpus[len(s.cpus)-1] >= maxCpus {
+		c.add(file, val.Line, SeverityWarning, "cpuAffinity of process %q includes CPU %d, this host has %d CPUs", name, s.cpus[len(s.cpus)-1], maxCpus)
+	}
+}
+
+// checkKind 检查节点的类型，类型正确时返回 true
+func (c *checker) checkKind(file, name string, val *yaml.Node, kind optionKind) bool {
+	// 空值表示使用默认值
+	if val.Kind == yaml.ScalarNode && val.Tag == "!!null" {
+		return false
+	}
+
+	switch kind {
+	case kindString:
+		if val.Kind != yaml.ScalarNode {
+			c.add(file, val.Line, SeverityError, "option %q must be a string", name)
+			return false
+		}
+	case kindInt:
+		if val.Kind != yaml.ScalarNode || val.Tag != "!!int" {
+			c.add(file, val.Line, SeverityError, "option %q must be an integer", name)
+			return false
+		}
+	case kindBool:
+		if val.Kind != yaml.ScalarNode || val.Tag != "!!bool" {
+			c.add(file, val.Line, SeverityError, "option %q must be true or false", name)
+			return false
+		}
+	case kindStringList:
+		if val.Kind == yaml.ScalarNode {
+			return true
+		}
+		if val.Kind != yaml.SequenceNode {
+			c.add(file, val.Line, SeverityError, "option %q must be a string or a list of strings", name)
+			return false
+		}
+		for _, item := range val.Content {
+			if item.Kind != yaml.ScalarNode {
+				c.add(file, item.Line, SeverityError, "option %q must be a string or a list of strings", name)
+				return false
+			}
+		}
+	case kindStringMap, kindMapping, kindProcesses:
+		if val.Kind != yaml.MappingNode {
+			c.add(file, val.Line, SeverityError, "option %q must be a mapping", name)
+			return false
+		}
+		if kind == kindStringMap {
+			for i := 1; i < len(val.Content); i += 2 {
+				if val.Content[i].Kind != yaml.ScalarNode {
+					c.add(file, val.Content[i].Line, SeverityError, "value of %q in option %q must be a scalar", val.Content[i-1].Value, name)
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func (c *checker) checkDir(file string, val *yaml.Node, name, baseDir string) {
+	if val.Value == "" {
+		return
+	}
+
+	dir := val.Value
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(baseDir, dir)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		c.add(file, val.Line, SeverityError, "%s directory %q does not exist", name, val.Value)
+	} else if !info.IsDir() {
+		c.add(file, val.Line, SeverityError, "%s %q is not a directory", name, val.Value)
+	}
+}
+
+func (c *checker) checkEnvFiles(file string, val *yaml.Node, baseDir string) {
+	nodes := []*yaml.Node{val}
+	if val.Kind == yaml.SequenceNode {
+		nodes = val.Content
+	}
+
+	for _, n := range nodes {
+		if n.Kind != yaml.ScalarNode || n.Value == "" {
+			continue
+		}
+
+		if _, err := LoadEnvFiles(baseDir, []string{n.Value}); err != nil {
+			c.add(file, n.Line, SeverityError, "envFile: %v", err)
+		}
+	}
+}
+
+// checkCommand 检查命令的可执行文件是否存在，通过 sh -c 执行的命令只检查 sh
+func (c *checker) checkCommand(file string, line int, name, cmd, root string) {
+	exe := strings.Fields(cmd)[0]
+	if strings.Contains(cmd, `"`) || strings.Contains(cmd, `'`) {
+		exe = "sh"
+	}
+
+	if strings.Contains(exe, "/") && !filepath.IsAbs(exe) {
+		exe = filepath.Join(root, exe)
+	}
+
+	if _, err := exec.LookPath(exe); err != nil {
+		c.add(file, line, SeverityError, "executable %q of process %q not found in PATH", exe, name)
+	}
+}
+
+// lookupKey 在映射节点中查找键（不区分大小写），不存在时返回 nil
+func lookupKey(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.EqualFold(m.Content[i].Value, key) {
+			return m.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func signalNames() []string {
+	names := make([]string, 0, len(sigTable))
+	for k := range sigTable {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorLine 从 YAML 解析错误中提取行号
+func yamlErrorLine(err error) int {
+	m := yamlLinePattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
@@ -0,0 +1,241 @@
+// Package supervisor 提供在伪终端中运行进程的功能，spm attach 通过控制连接读写进程的伪终端
+package supervisor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	consoleDrainTimeout = 200 * time.Millisecond // 进程退出后，子进程仍持有伪终端时继续读取输出的时间
+	consoleClientBuffer = 256                    // 每个 attach 连接缓存的输出块数，写满时断开该连接
+)
+
+// console 在伪终端中运行的进程的主设备端
+//
+// 进程的输出写入标准输出日志，同时转发给所有 attach 的连接；
+// 所有连接的输入都写入伪终端，窗口大小以最后一次调整为准
+type console struct {
+	master *os.File
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	closed  bool
+}
+
+// openPty 打开一对伪终端设备，初始窗口大小为 80x24
+//
+// 返回：
+//
+//	*os.File: 主设备，由守护进程读写
+//	*os.File: 从设备，作为进程的标准输入、输出和错误
+//	error: 系统不支持伪终端或设备打开失败
+func openPty() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ptn int
+	if err := controlFd(master, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return fmt.Errorf("unlock pty: %w", err)
+		}
+
+		n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+		if err != nil {
+			return fmt.Errorf("get pty number: %w", err)
+		}
+		ptn = n
+
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: 24, Col: 80})
+	}); err != nil {
+		_ = master.Close()
+		return nil, nil, err
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", ptn), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		_ = master.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
+
+// controlFd 在文件描述符上执行 ioctl 等操作，不会像 Fd() 那样把文件切换为阻塞模式
+func controlFd(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	if err := rc.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+
+	return fnErr
+}
+
+// setupConsole 让进程在新的伪终端中运行，替代 setupStreams
+//
+// 伪终端中标准输出和标准错误无法区分，全部写入标准输出日志，标准错误日志不会有内容。
+// 返回的函数在 Wait 之后（或启动失败时）调用，关闭守护进程持有的从设备，
+// 读完剩余的输出后结束日志监控
+func (p *Process) setupConsole(cmd *exec.Cmd) (func(), error) {
+	master, slave, err := openPty()
+	if err != nil {
+		return nil, err
+	}
+	p.chownToCredential(slave.Name())
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+
+	// 进程成为新会话的首进程，伪终端作为它的控制终端，Ctrl-C 等按键产生的信号发给它的前台进程组；
+	// 会话首进程不能再设置进程组，前台模式下的 Setpgid 不再需要
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+
+	if err := p.ErrLog.Close(); err != nil {
+		p.logger.Warnf("STDERR log file close error: %v", err)
+	}
+
+	c := &console{master: master, clients: make(map[chan []byte]struct{})}
+	outR, outW := io.Pipe()
+
+	p.wg.Add(2)
+	go p.watchLog("STDOUT", outR)
+	go func() {
+		defer p.wg.Done()
+		c.pump(outW)
+	}()
+
+	p.mu.Lock()
+	p.console = c
+	p.mu.Unlock()
+
+	return func() {
+		_ = slave.Close()
+		// 没有其他进程持有从设备时读取立即返回 EIO，否则最多再等待 consoleDrainTimeout
+		_ = master.SetReadDeadline(time.Now().Add(consoleDrainTimeout))
+	}, nil
+}
+
+// currentConsole 返回进程的伪终端，进程没有在伪终端中运行或已经退出时返回 nil
+func (p *Process) currentConsole() *console {
+	p.mu.Lock()
+	c := p.console
+	p.mu.Unlock()
+
+	if c == nil || c.isClosed() {
+		return nil
+	}
+
+	return c
+}
+
+// pump 把伪终端的输出写入日志管道并转发给 attach 的连接，伪终端关闭后关闭所有连接
+func (c *console) pump(w *io.PipeWriter) {
+	defer func() {
+		_ = w.Close()
+		_ = c.master.Close()
+
+		c.mu.Lock()
+		c.closed = true
+		for ch := range c.clients {
+			close(ch)
+		}
+		c.clients = nil
+		c.mu.Unlock()
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := c.master.Read(buf)
+		if n > 0 {
+			data := append([]byte(nil), buf[:n]...)
+			_, _ = w.Write(data)
+			c.broadcast(data)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// broadcast 把一块输出发给所有连接，连接的缓存写满时断开该连接，不阻塞进程的输出
+func (c *console) broadcast(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for ch := range c.clients {
+		select {
+		case ch <- data:
+		default:
+			delete(c.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe 订阅伪终端的输出，伪终端关闭或连接跟不上输出时 channel 被关闭
+func (c *console) subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, consoleClientBuffer)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	c.clients[ch] = struct{}{}
+
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if _, ok := c.clients[ch]; ok {
+			delete(c.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// isClosed 判断伪终端是否已经关闭，即进程已经退出
+func (c *console) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// write 把输入写入伪终端
+func (c *console) write(data []byte) error {
+	_, err := c.master.Write(data)
+	return err
+}
+
+// resize 调整伪终端的窗口大小，内核向进程的前台进程组发送 SIGWINCH
+func (c *console) resize(rows, cols uint16) error {
+	return controlFd(c.master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
+	})
+}
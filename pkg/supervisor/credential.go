@@ -0,0 +1,160 @@
+// Package supervisor 提供以其他用户和组运行进程的功能
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// resolveCredential 解析进程配置中的 user、group 和 groups
+//
+// 返回：
+//
+//	*syscall.Credential: 进程的 UID、GID 和附加组，三项都为空时返回 nil，进程以守护进程的用户运行
+//	error: 用户或组不存在
+//
+// 默认值与 su/sudo 一致：
+//   - 只设置 user 时，主组为该用户的主组，附加组为该用户所属的所有组
+//   - 只设置 group 时，以守护进程的用户运行，只替换主组
+//   - 设置 groups 时替换默认的附加组
+func resolveCredential(opt *ProcessOption) (*syscall.Credential, error) {
+	if opt.User == "" && opt.Group == "" && len(opt.Groups) == 0 {
+		return nil, nil
+	}
+
+	cred := &syscall.Credential{
+		Uid: uint32(os.Geteuid()),
+		Gid: uint32(os.Getegid()),
+	}
+
+	if opt.User != "" {
+		u, err := lookupUser(opt.User)
+		if err != nil {
+			return nil, err
+		}
+
+		uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+		cred.Uid = uint32(uid)
+		cred.Gid = uint32(gid)
+
+		if len(opt.Groups) == 0 {
+			ids, err := u.GroupIds()
+			if err != nil {
+				return nil, fmt.Errorf("groups of user %s: %w", opt.User, err)
+			}
+			cred.Groups = parseIDs(ids)
+		}
+	}
+
+	if opt.Group != "" {
+		gid, err := lookupGroup(opt.Group)
+		if err != nil {
+			return nil, err
+		}
+		cred.Gid = gid
+	}
+
+	if len(opt.Groups) > 0 {
+		cred.Groups = make([]uint32, 0, len(opt.Groups))
+		for _, name := range opt.Groups {
+			gid, err := lookupGroup(name)
+			if err != nil {
+				return nil, err
+			}
+			cred.Groups = append(cred.Groups, gid)
+		}
+	}
+
+	return cred, nil
+}
+
+// lookupUser 按用户名或 UID 查找用户，用户必须存在于系统的用户数据库中
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+
+	var unknown user.UnknownUserError
+	if _, numErr := strconv.ParseUint(name, 10, 32); numErr == nil && errors.As(err, &unknown) {
+		return user.LookupId(name)
+	}
+
+	return nil, err
+}
+
+// lookupGroup 按组名或 GID 查找组，组必须存在于系统的组数据库中
+func lookupGroup(name string) (uint32, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		var unknown user.UnknownGroupError
+		if _, numErr := strconv.ParseUint(name, 10, 32); numErr != nil || !errors.As(err, &unknown) {
+			return 0, err
+		}
+
+		g, err = user.LookupGroupId(name)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	return uint32(gid), err
+}
+
+// parseIDs 把字符串形式的 ID 列表转换为数字，忽略无法解析的项
+func parseIDs(ids []string) []uint32 {
+	result := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		if n, err := strconv.ParseUint(id, 10, 32); err == nil {
+			result = append(result, uint32(n))
+		}
+	}
+
+	return result
+}
+
+// sysProcAttr 返回以配置的用户和组启动进程所需的 SysProcAttr，没有配置时返回 nil
+//
+// 只有 root 可以切换到其他用户；非 root 的守护进程只允许配置为自身的用户，
+// 此时不调用 setgroups（需要 CAP_SETGID），附加组保持不变
+func (p *Process) sysProcAttr() (*syscall.SysProcAttr, error) {
+	return credentialAttr(p.Options)
+}
+
+// credentialAttr 按进程配置的用户和组生成 SysProcAttr，规则与 sysProcAttr 相同
+func credentialAttr(opts *ProcessOption) (*syscall.SysProcAttr, error) {
+	cred := opts.credential
+	if cred == nil {
+		return nil, nil
+	}
+
+	euid := uint32(os.Geteuid())
+	if euid != 0 && cred.Uid != euid {
+		return nil, fmt.Errorf("cannot run as user %s: not running as root", opts.User)
+	}
+
+	c := *cred
+	c.NoSetGroups = euid != 0
+
+	return &syscall.SysProcAttr{Credential: &c}, nil
+}
+
+// chownToCredential 把守护进程创建的日志和 PID 文件的所有者改为进程的用户和组
+//
+// 守护进程不是 root 或进程没有配置用户时不做任何修改
+func (p *Process) chownToCredential(path string) {
+	cred := p.Options.credential
+	if cred == nil || os.Geteuid() != 0 {
+		return
+	}
+
+	if err := os.Chown(path, int(cred.Uid), int(cred.Gid)); err != nil {
+		p.logger.Warn(err)
+	}
+}
@@ -1,5 +1,7 @@
 package supervisor
 
+import "fmt"
+
 type ActionCtl int
 
 const (
@@ -12,21 +14,71 @@ const (
 	ActionRestart
 	ActionShutdown
 	ActionReload
+	ActionRemove
+	ActionSignal
+	ActionAttach
 )
 
+var actionNames = map[ActionCtl]string{
+	ActionRun:      "run",
+	ActionLog:      "log",
+	ActionKill:     "kill",
+	ActionStart:    "start",
+	ActionStop:     "stop",
+	ActionStatus:   "status",
+	ActionRestart:  "restart",
+	ActionShutdown: "shutdown",
+	ActionReload:   "reload",
+	ActionRemove:   "rm",
+	ActionSignal:   "signal",
+	ActionAttach:   "attach",
+}
+
+func (a ActionCtl) String() string {
+	if name, ok := actionNames[a]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("action(%d)", int(a))
+}
+
 var actionResponse = map[ActionCtl]string{
 	ActionRun:     "Run command successfully",
 	ActionStart:   "Start processes successfully",
 	ActionStop:    "Stop processes successfully",
 	ActionStatus:  "Check processes status successfully",
 	ActionRestart: "Restart processes successfully",
+	ActionRemove:  "Remove processes successfully",
 }
 
 type ActionMsg struct {
-	Action    ActionCtl
-	WorkDir   string
-	Procfile  string
-	Projects  string
-	Processes string
-	CmdLine   []string
+	Action    ActionCtl `codec:"action"`
+	WorkDir   string    `codec:"work_dir"`
+	Procfile  string    `codec:"procfile"`
+	Projects  string    `codec:"projects"`
+	Processes string    `codec:"processes"`
+	CmdLine   []string  `codec:"cmd_line"`
+	DryRun    bool      `codec:"dry_run"`
+	Verbose   bool      `codec:"verbose"`
+	Watch     bool      `codec:"watch"` // ActionStart 为启动的进程开启文件监控，spm stop 时关闭
+
+	// spm run 的参数
+	Name    string            `codec:"name"`    // 进程名，为空时使用可执行文件名
+	Env     map[string]string `codec:"env"`     // 进程的环境变量
+	Restart string            `codec:"restart"` // 重启策略：never、on-failure、always
+	Tty     bool              `codec:"tty"`     // 在伪终端中运行
+
+	Signal string `codec:"signal"` // ActionSignal 发送的信号，例如 HUP、SIGUSR1、15
+	Tail   int    `codec:"tail"`   // ActionLog 先发送的历史日志行数
+}
+
+// AttachMsg ActionAttach 应答之后双向传输的消息
+//
+// 客户端发送 Data（键盘输入）或 Rows、Cols（窗口大小）；
+// 守护进程发送 Data（进程输出），进程退出时发送 Exited 为 true 的消息后关闭连接
+type AttachMsg struct {
+	Data   []byte `codec:"data,omitempty"`
+	Rows   uint16 `codec:"rows,omitempty"`
+	Cols   uint16 `codec:"cols,omitempty"`
+	Exited bool   `codec:"exited,omitempty"`
 }
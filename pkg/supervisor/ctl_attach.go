@@ -0,0 +1,155 @@
+// Package supervisor 提供通过控制套接字连接进程伪终端的功能
+package supervisor
+
+import (
+	"context"
+	"fmt"
+
+	"spm/pkg/utils"
+)
+
+// attachConsole 处理 ActionAttach：先发送应答，之后把进程伪终端的输出作为 AttachMsg 帧发送，
+// 并把客户端发来的输入和窗口大小写入伪终端，直到进程退出、客户端关闭连接或守护进程退出
+//
+// 返回的 ResponseMsg 只用于记录监控指标，应答已经发送
+func (se *SpmSession) attachConsole(msg *ActionMsg) (*ResponseMsg, ResponseCtl) {
+	p, res := se.singleProcess(msg)
+	if res != nil {
+		return res, se.sendResponse(res, ResponseMsgErr)
+	}
+
+	c := p.currentConsole()
+	if c == nil {
+		res = &ResponseMsg{
+			Code:    409,
+			Message: fmt.Sprintf("Process %s is not running in a terminal, set tty: true in Procfile.options", p.FullName),
+		}
+		return res, se.sendResponse(res, ResponseMsgErr)
+	}
+
+	output, unsub := c.subscribe()
+	defer unsub()
+
+	res = &ResponseMsg{Code: 200, Message: fmt.Sprintf("Attached to %s", p.FullName)}
+	if se.sendResponse(res, ResponseNormal) == ResponseMsgErr {
+		return res, ResponseMsgErr
+	}
+	se.logger.Infof("%s attached to %s", se.peer, p.FullName)
+
+	// 客户端随时可能发送输入，不设置读超时；读到错误说明连接已经关闭
+	se.codec.readTimeout = 0
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+
+		for {
+			_, buf, err := se.codec.ReadFrame()
+			if err != nil {
+				return
+			}
+
+			in, err := decodeData[AttachMsg](buf)
+			if err != nil {
+				se.logger.Error(err)
+				return
+			}
+
+			if in.Rows > 0 && in.Cols > 0 {
+				if err := c.resize(in.Rows, in.Cols); err != nil {
+					se.logger.Warn(err)
+				}
+			}
+			if len(in.Data) > 0 {
+				if err := c.write(in.Data); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			se.logger.Infof("%s detached from %s", se.peer, p.FullName)
+			return res, ResponseNormal
+		case <-utils.FinishChan:
+			return res, ResponseNormal
+		case data, ok := <-output:
+			if !ok {
+				if !c.isClosed() {
+					// 连接跟不上进程的输出，已经被断开
+					se.logger.Warnf("%s is too slow to read the output of %s, disconnected", se.peer, p.FullName)
+					return res, ResponseMsgErr
+				}
+				_ = writeMsg(se.codec, se.reqID, &AttachMsg{Exited: true})
+				return res, ResponseNormal
+			}
+			if err := writeMsg(se.codec, se.reqID, &AttachMsg{Data: data}); err != nil {
+				return res, ResponseMsgErr
+			}
+		}
+	}
+}
+
+// AttachConsole 通过控制连接连接进程的伪终端
+//
+// 参数：
+//
+//	ctx: 取消时断开连接（detach）并返回 ctx.Err()，进程继续运行
+//	ep: 守护进程的地址
+//	msg: Action 为 ActionAttach 的控制消息，Processes 为进程名
+//	input: 发送给守护进程的输入和窗口大小，关闭后不再发送但保持连接
+//	output: 处理进程的每一块输出，返回错误时断开连接并返回该错误
+//
+// 返回：
+//
+//	*ResponseMsg: 守护进程的应答，状态码不是 200 时不会调用 output
+//	error: 进程退出时为 nil，否则为连接断开的原因
+func AttachConsole(ctx context.Context, ep *Endpoint, msg *ActionMsg, input <-chan *AttachMsg, output func([]byte) error) (*ResponseMsg, error) {
+	c, res, closeConn, err := openStream(ctx, ep, msg)
+	if err != nil || res.Code != 200 {
+		return res, err
+	}
+	defer closeConn()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case in, ok := <-input:
+				if !ok {
+					return
+				}
+				if err := writeMsg(c.codec, c.reqID, in); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		data, err := c.recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			return res, err
+		}
+
+		out, err := decodeData[AttachMsg](data)
+		if err != nil {
+			return res, err
+		}
+		if out.Exited {
+			return res, nil
+		}
+
+		if err := output(out.Data); err != nil {
+			return res, err
+		}
+	}
+}
@@ -1,108 +1,297 @@
 package supervisor
 
 import (
-	"encoding/binary"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
-	"strconv"
+	"time"
 
 	"spm/pkg/config"
 	"spm/pkg/logger"
-
-	"go.uber.org/zap"
+	"spm/pkg/utils"
+	"spm/pkg/utils/constants"
 )
 
+// clientReadTimeout 客户端等待守护进程响应的最长时间，停止大量进程时可能需要较长时间
+const clientReadTimeout = 5 * time.Minute
+
+// ErrDaemonUnavailable 无法连接到守护进程的控制套接字，通常是守护进程没有运行
+var ErrDaemonUnavailable = errors.New("supervisor daemon is not running")
+
 type SpmClient struct {
-	sock   *spmSocket
-	logger *zap.SugaredLogger
+	codec *frameCodec
+	reqID uint64
+	token string
 }
 
 func ClientRun(msg *ActionMsg) []*ProcInfo {
-	c := new(SpmClient)
-	c.logger = logger.Logging("spm-cli")
+	res := ClientSend(msg)
+	if res == nil {
+		return nil
+	}
 
-	conn, err := net.Dial("unix", config.GetConfig().Socket)
+	return res.Processes
+}
+
+// ClientSend 发送控制消息并返回完整的响应，失败时在 stderr 输出错误并返回 nil
+func ClientSend(msg *ActionMsg) *ResponseMsg {
+	log := logger.Logging("spm-cli")
+
+	var res *ResponseMsg
+	ep, err := NewEndpoint(config.GetConfig())
+	if err == nil {
+		res, err = RequestTo(context.Background(), ep, msg)
+	}
 	if err != nil {
-		c.logger.Error(err)
+		// 守护进程在发送响应前关闭连接（例如正在关闭），不视为错误
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		log.Error(err)
 		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 		return nil
 	}
 
+	_, _ = fmt.Fprintf(os.Stdout, "%d\t%s\n\n", res.Code, res.Message)
+
+	return res
+}
+
+// Endpoint 控制请求的目标
+//
+// Network 为 unix 时 Address 是控制套接字路径；为 tcp 时 Address 是 host:port，
+// 必须设置 TLS，Token 在握手时提交给守护进程
+type Endpoint struct {
+	Network string
+	Address string
+	TLS     *tls.Config
+	Token   string
+}
+
+// NewEndpoint 根据 spm.yml 的 client 配置创建请求目标
+//
+// client.host 为空时使用本机的控制套接字 cfg.Socket，否则通过 TLS 连接远程守护进程：
+//   - client.caFile 为空时使用系统 CA 验证守护进程的证书
+//   - client.certFile 和 client.keyFile 用于 mTLS
+//   - client.serverName 为空时取 host 中的主机名
+func NewEndpoint(cfg *config.Config) (*Endpoint, error) {
+	if cfg.Client.Host == "" {
+		return &Endpoint{Network: "unix", Address: cfg.Socket}, nil
+	}
+
+	addr := cfg.Client.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, constants.DefaultRemotePort)
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: host,
+	}
+	if cfg.Client.ServerName != "" {
+		tlsCfg.ServerName = cfg.Client.ServerName
+	}
+
+	if cfg.Client.CAFile != "" {
+		pool, err := loadCertPool(cfg.Client.CAFile)
+		if err != nil {
+			return nil, &config.ConfigError{Path: cfg.Client.CAFile, Op: "load", Err: err}
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.Client.CertFile != "" || cfg.Client.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Client.CertFile, cfg.Client.KeyFile)
+		if err != nil {
+			return nil, &config.ConfigError{Path: cfg.Client.CertFile, Op: "load", Err: err}
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return &Endpoint{
+		Network: "tcp",
+		Address: addr,
+		TLS:     tlsCfg,
+		Token:   cfg.Client.Token,
+	}, nil
+}
+
+// Request 连接守护进程的控制套接字，发送一个控制请求并返回响应
+//
+// 参数：
+//
+//	ctx: 取消或超时时中断连接并返回 ctx.Err()
+//	socket: 控制套接字路径
+//	msg: 控制消息
+//
+// 返回：
+//
+//	*ResponseMsg: 守护进程的响应，包含状态码和消息
+//	error: 连接失败时包装 ErrDaemonUnavailable；协议版本不一致时为 *VersionMismatchError
+//
+// 与 ClientSend 不同，本函数不输出任何信息，也不依赖日志初始化，适合作为库调用
+func Request(ctx context.Context, socket string, msg *ActionMsg) (*ResponseMsg, error) {
+	return RequestTo(ctx, &Endpoint{Network: "unix", Address: socket}, msg)
+}
+
+// RequestTo 与 Request 相同，但可以通过 TLS 连接远程守护进程的 TCP 监听
+//
+// TLS 握手失败（例如证书无法验证）时返回的错误不包装 ErrDaemonUnavailable
+func RequestTo(ctx context.Context, ep *Endpoint, msg *ActionMsg) (*ResponseMsg, error) {
+	conn, err := dialEndpoint(ctx, ep)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
 	defer func() {
 		_ = conn.Close()
 	}()
 
-	c.sock = &spmSocket{
-		conn: conn,
+	// ctx 结束时关闭连接，使阻塞中的读写立即返回
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	c := &SpmClient{
+		codec: newFrameCodec(conn),
+		reqID: nextRequestID(),
+		token: ep.Token,
 	}
+	c.codec.readTimeout = clientReadTimeout
 
-	var data []byte
+	res, err := c.roundTrip(msg)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 
-	data, err = encodeData(msg)
-	if err != nil {
-		c.logger.Error(err)
-		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		return nil
+	return res, err
+}
+
+// dialEndpoint 建立到守护进程的连接，TCP 连接在返回前完成 TLS 握手
+func dialEndpoint(ctx context.Context, ep *Endpoint) (net.Conn, error) {
+	if ep.Network != "tcp" {
+		var dialer net.Dialer
+
+		conn, err := dialer.DialContext(ctx, ep.Network, ep.Address)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDaemonUnavailable, err)
+		}
+		return conn, nil
 	}
 
-	size := make([]byte, strconv.IntSize)
-	binary.BigEndian.PutUint64(size, uint64(len(data)))
+	if ep.TLS == nil {
+		return nil, fmt.Errorf("connect %s: TLS is required for tcp connections", ep.Address)
+	}
 
-	err = c.sock.Send(size)
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", ep.Address)
 	if err != nil {
-		c.logger.Error(err)
-		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		return nil
+		return nil, fmt.Errorf("%w: %v", ErrDaemonUnavailable, err)
 	}
 
-	err = c.sock.Send(data)
-	if err != nil {
-		c.logger.Error(err)
-		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		return nil
+	tlsConn := tls.Client(conn, ep.TLS)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("tls handshake with %s: %w", ep.Address, err)
 	}
 
-	var length uint64
-	data, err = c.sock.Recv(strconv.IntSize)
+	return tlsConn, nil
+}
+
+// loadCertPool 读取 PEM 格式的 CA 证书
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		if err != io.EOF {
-			c.logger.Error(err)
-			_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-			return nil
-		}
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+
+	return pool, nil
+}
+
+// roundTrip 完成握手并发送一个控制请求
+func (c *SpmClient) roundTrip(msg *ActionMsg) (*ResponseMsg, error) {
+	if err := c.handshake(); err != nil {
+		return nil, err
 	}
 
-	if data != nil {
-		length = binary.BigEndian.Uint64(data)
+	if err := writeMsg(c.codec, c.reqID, msg); err != nil {
+		return nil, err
 	}
 
-	data, err = c.sock.Recv(length)
+	data, err := c.recv()
 	if err != nil {
-		if err != io.EOF {
-			c.logger.Error(err)
-			_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-			return nil
-		}
+		return nil, err
 	}
 
-	if len(data) == 0 {
-		return nil
+	return decodeData[ResponseMsg](data)
+}
+
+// handshake 向守护进程发送握手消息，协议版本不一致时返回 *VersionMismatchError
+//
+// 旧版守护进程不识别握手消息，会按旧版协议返回数组编码的响应，
+// 据此判断守护进程使用的是旧版协议
+func (c *SpmClient) handshake() error {
+	if err := writeMsg(c.codec, c.reqID, newHello(c.token)); err != nil {
+		return err
 	}
 
-	res, err := decodeData[ResponseMsg](data)
+	data, err := c.recv()
 	if err != nil {
-		c.logger.Error(err)
-		_, _ = fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		return nil
+		return err
 	}
 
-	_, _ = fmt.Fprintf(os.Stdout, "%d\t%s\n\n", res.Code, res.Message)
+	if isLegacyFrame(data) {
+		return &VersionMismatchError{
+			ClientProtocol: ProtocolVersion,
+			DaemonProtocol: 1,
+			ClientVersion:  utils.BinaryVersion,
+		}
+	}
 
-	if res.Processes != nil {
-		return res.Processes
+	reply, err := decodeData[HelloReply](data)
+	if err != nil {
+		return err
+	}
+
+	if !reply.Accepted {
+		return &VersionMismatchError{
+			ClientProtocol: ProtocolVersion,
+			DaemonProtocol: reply.Protocol,
+			ClientVersion:  utils.BinaryVersion,
+			DaemonVersion:  reply.Version,
+		}
 	}
 
 	return nil
 }
+
+// recv 读取一帧响应，并检查响应的 request ID 与请求一致
+//
+// 旧版守护进程不回传 request ID（为 0），不做检查
+func (c *SpmClient) recv() ([]byte, error) {
+	id, data, err := c.codec.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	if id != 0 && id != c.reqID {
+		return nil, fmt.Errorf("unexpected response for request %d, expected %d", id, c.reqID)
+	}
+
+	return data, nil
+}
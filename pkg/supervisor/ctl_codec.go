@@ -0,0 +1,136 @@
+// Package supervisor 提供控制连接的分帧编解码功能
+package supervisor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// 帧格式：
+//
+//	+----------------+----------------+-----------------------+---------+
+//	| length (8字节) | request ID (8) | 保留 (48字节，全为0)  | payload |
+//	+----------------+----------------+-----------------------+---------+
+//
+// 帧头长度沿用旧版协议的 64 字节（旧版使用 strconv.IntSize 作为长度头的字节数），
+// 旧版只使用前 8 字节表示消息长度，其余字节为 0，因此旧版客户端的 request ID 为 0
+const (
+	frameHeaderSize = 64
+
+	// MaxFrameSize 单帧消息的最大字节数，超过时拒绝读取，防止恶意长度耗尽内存
+	MaxFrameSize = 16 << 20
+
+	// defaultReadTimeout 等待对端发送一帧消息的最长时间
+	defaultReadTimeout = 10 * time.Second
+
+	// defaultWriteTimeout 发送一帧消息的最长时间
+	defaultWriteTimeout = 10 * time.Second
+)
+
+// ErrFrameTooLarge 对端声明的消息长度超过 MaxFrameSize
+var ErrFrameTooLarge = errors.New("frame exceeds maximum message size")
+
+// lastRequestID 客户端生成 request ID 的计数器，以启动时间为种子避免多个客户端进程重复
+var lastRequestID = uint64(time.Now().UnixNano())
+
+// nextRequestID 生成新的非零 request ID
+func nextRequestID() uint64 {
+	id := atomic.AddUint64(&lastRequestID, 1)
+	if id == 0 {
+		id = atomic.AddUint64(&lastRequestID, 1)
+	}
+
+	return id
+}
+
+// frameCodec 在连接上读写带长度头的消息帧
+//
+// 读取使用 io.ReadFull 保证读满一帧，读写都设置超时时间，
+// 超时为 0 表示不设置对应方向的超时
+type frameCodec struct {
+	conn         net.Conn
+	maxSize      uint64
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// newFrameCodec 使用默认的大小限制和超时时间创建编解码器
+func newFrameCodec(conn net.Conn) *frameCodec {
+	return &frameCodec{
+		conn:         conn,
+		maxSize:      MaxFrameSize,
+		readTimeout:  defaultReadTimeout,
+		writeTimeout: defaultWriteTimeout,
+	}
+}
+
+// WriteFrame 发送一帧消息，帧头和内容在一次写操作中发送
+func (c *frameCodec) WriteFrame(id uint64, data []byte) error {
+	if uint64(len(data)) > c.maxSize {
+		return fmt.Errorf("%w: %d > %d bytes", ErrFrameTooLarge, len(data), c.maxSize)
+	}
+
+	buf := make([]byte, frameHeaderSize+len(data))
+	binary.BigEndian.PutUint64(buf[0:8], uint64(len(data)))
+	binary.BigEndian.PutUint64(buf[8:16], id)
+	copy(buf[frameHeaderSize:], data)
+
+	if c.writeTimeout > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return err
+		}
+	}
+
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// ReadFrame 读取一帧消息，返回 request ID 和消息内容
+//
+// 对端在发送帧头之前关闭连接时返回 io.EOF；帧不完整时返回 io.ErrUnexpectedEOF
+func (c *frameCodec) ReadFrame() (uint64, []byte, error) {
+	if c.readTimeout > 0 {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint64(header[0:8])
+	id := binary.BigEndian.Uint64(header[8:16])
+
+	if size > c.maxSize {
+		return id, nil, fmt.Errorf("%w: %d > %d bytes", ErrFrameTooLarge, size, c.maxSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return id, nil, err
+	}
+
+	return id, data, nil
+}
+
+// Close 关闭底层连接
+func (c *frameCodec) Close() error {
+	return c.conn.Close()
+}
+
+// writeMsg 编码消息并作为一帧发送
+func writeMsg[T any](c *frameCodec, id uint64, v *T) error {
+	data, err := encodeData(v)
+	if err != nil {
+		return err
+	}
+
+	return c.WriteFrame(id, data)
+}
@@ -0,0 +1,206 @@
+// Package supervisor 提供通过控制套接字跟踪进程日志的功能
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"spm/pkg/utils"
+)
+
+// logHistory 读取进程日志文件中最近的 tail 行，stream 为空时先 stdout 后 stderr
+func logHistory(p *Process, stream string, tail int) ([]*LogLine, error) {
+	if tail <= 0 {
+		return nil, nil
+	}
+
+	outPath, errPath, err := p.LogPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*LogLine, 0)
+	for _, f := range []struct{ stream, path string }{{"stdout", outPath}, {"stderr", errPath}} {
+		if stream != "" && stream != f.stream {
+			continue
+		}
+
+		lines, err := tailFile(f.path, tail)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return history, err
+		}
+
+		for _, line := range lines {
+			history = append(history, &LogLine{Process: p.FullName, Stream: f.stream, Line: line})
+		}
+	}
+
+	return history, nil
+}
+
+// streamLogs 处理 ActionLog：先发送应答，再把最近 Tail 行历史日志和之后的新输出逐行作为 LogLine 帧发送，
+// 直到客户端关闭连接或守护进程退出
+//
+// 返回的 ResponseMsg 只用于记录监控指标，应答已经发送
+func (se *SpmSession) streamLogs(msg *ActionMsg) (*ResponseMsg, ResponseCtl) {
+	p, res := se.singleProcess(msg)
+	if res != nil {
+		return res, se.sendResponse(res, ResponseMsgErr)
+	}
+	name := p.FullName
+
+	// 先订阅再读取历史日志，避免两者之间的输出丢失
+	lines, unsub := SubscribeLogs(name)
+	defer unsub()
+
+	res = &ResponseMsg{Code: 200, Message: fmt.Sprintf("Streaming logs of %s", name)}
+	if se.sendResponse(res, ResponseNormal) == ResponseMsgErr {
+		return res, ResponseMsgErr
+	}
+
+	history, err := logHistory(p, "", min(msg.Tail, maxTailLines))
+	if err != nil {
+		se.logger.Error(err)
+	}
+	for _, line := range history {
+		if err := writeMsg(se.codec, se.reqID, line); err != nil {
+			return res, ResponseMsgErr
+		}
+	}
+
+	// 客户端在请求之后不再发送消息，读到错误说明连接已经关闭
+	se.codec.readTimeout = 0
+	closed := make(chan struct{})
+	go func() {
+		_, _, _ = se.codec.ReadFrame()
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return res, ResponseNormal
+		case <-utils.FinishChan:
+			return res, ResponseNormal
+		case line, ok := <-lines:
+			if !ok {
+				return res, ResponseNormal
+			}
+			if err := writeMsg(se.codec, se.reqID, line); err != nil {
+				return res, ResponseMsgErr
+			}
+		}
+	}
+}
+
+// singleProcess 查找只针对一个进程的请求中的进程，进程名没有项目名时使用 WorkDir 所在的项目
+//
+// 进程名缺失或包含多个进程时返回 400，进程不存在时返回 404 的应答
+func (se *SpmSession) singleProcess(msg *ActionMsg) (*Process, *ResponseMsg) {
+	name := msg.Processes
+	if name == "" || name == "*" || strings.Contains(name, ";") {
+		return nil, &ResponseMsg{Code: 400, Message: "Exactly one process name is required"}
+	}
+
+	if !strings.Contains(name, "::") {
+		appName, err := GetAppName(msg.WorkDir)
+		if err != nil {
+			res, _ := se.errorResponse(err)
+			return nil, res
+		}
+		name = fmt.Sprintf("%s::%s", appName, name)
+	}
+
+	p := se.sv.procTable.Get(name)
+	if p == nil {
+		return nil, &ResponseMsg{Code: 404, Message: fmt.Sprintf("Process not found: %s", name)}
+	}
+
+	return p, nil
+}
+
+// StreamLogs 通过控制连接跟踪单个进程的日志，每收到一行调用一次 fn
+//
+// 参数：
+//
+//	ctx: 取消时关闭连接并返回 ctx.Err()
+//	ep: 守护进程的地址
+//	msg: Action 为 ActionLog 的控制消息，Processes 为进程名，Tail 为先发送的历史行数
+//	fn: 处理每一行日志，返回错误时停止跟踪并返回该错误
+//
+// 返回：
+//
+//	*ResponseMsg: 守护进程的应答，状态码不是 200 时不会调用 fn
+//	error: 连接失败、握手失败或跟踪中断的原因
+func StreamLogs(ctx context.Context, ep *Endpoint, msg *ActionMsg, fn func(*LogLine) error) (*ResponseMsg, error) {
+	c, res, closeConn, err := openStream(ctx, ep, msg)
+	if err != nil || res.Code != 200 {
+		return res, err
+	}
+	defer closeConn()
+
+	for {
+		data, err := c.recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			return res, err
+		}
+
+		line, err := decodeData[LogLine](data)
+		if err != nil {
+			return res, err
+		}
+
+		if err := fn(line); err != nil {
+			return res, err
+		}
+	}
+}
+
+// openStream 连接守护进程并发送请求，用于应答之后继续在同一连接上收发消息的操作
+//
+// 应答的状态码为 200 时返回的连接不设置读超时，由调用方调用 closeConn 关闭；
+// 其他情况下连接已经关闭。ctx 取消时连接随之关闭，此时返回 ctx.Err()
+func openStream(ctx context.Context, ep *Endpoint, msg *ActionMsg) (c *SpmClient, res *ResponseMsg, closeConn func(), err error) {
+	conn, err := dialEndpoint(ctx, ep)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, nil, ctx.Err()
+		}
+		return nil, nil, nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	closeConn = func() {
+		stop()
+		_ = conn.Close()
+	}
+
+	c = &SpmClient{
+		codec: newFrameCodec(conn),
+		reqID: nextRequestID(),
+		token: ep.Token,
+	}
+	c.codec.readTimeout = clientReadTimeout
+
+	res, err = c.roundTrip(msg)
+	if err != nil || res.Code != 200 {
+		closeConn()
+		if err != nil && ctx.Err() != nil {
+			return nil, nil, nil, ctx.Err()
+		}
+		return nil, res, nil, err
+	}
+
+	// 之后的消息可能很长时间才有一条
+	c.codec.readTimeout = 0
+
+	return c, res, closeConn, nil
+}
@@ -0,0 +1,128 @@
+// Package supervisor 提供控制协议的版本握手功能
+package supervisor
+
+import (
+	"fmt"
+
+	"spm/pkg/utils"
+
+	"github.com/ugorji/go/codec"
+)
+
+// ProtocolVersion 当前控制协议的版本号
+//
+// 版本历史：
+//   - 1: 旧版协议，没有握手，消息按字段位置编码（StructToArray）
+//   - 2: 连接建立后先握手，消息按字段名编码为 map
+//
+// 修改 ActionMsg 或 ResponseMsg 时，只新增字段不需要升级版本；
+// 删除字段或改变字段含义时必须递增版本号
+const ProtocolVersion = 2
+
+// HelloMsg 客户端在连接建立后发送的握手消息
+//
+// Action 字段固定为 ActionLog，旧版守护进程会把握手消息解码成一个
+// 未实现的 ActionLog 请求并返回 404，而不会执行任何操作
+//
+// Token 只在连接 TCP 监听时使用，Unix 套接字按对端凭据检查权限，忽略该字段
+type HelloMsg struct {
+	Action   ActionCtl `codec:"Action"`
+	Protocol int       `codec:"protocol"`
+	Version  string    `codec:"version"`
+	Token    string    `codec:"token,omitempty"`
+}
+
+// HelloReply 守护进程对握手消息的应答
+type HelloReply struct {
+	Protocol int    `codec:"protocol"`
+	Version  string `codec:"version"`
+	Accepted bool   `codec:"accepted"`
+	Message  string `codec:"message"`
+}
+
+// VersionMismatchError 客户端与守护进程的协议版本不一致
+type VersionMismatchError struct {
+	ClientProtocol int
+	DaemonProtocol int
+	ClientVersion  string
+	DaemonVersion  string
+}
+
+func (e *VersionMismatchError) Error() string {
+	client := fmt.Sprintf("protocol %d", e.ClientProtocol)
+	if e.ClientVersion != "" {
+		client = fmt.Sprintf("v%s (%s)", e.ClientVersion, client)
+	}
+
+	daemon := fmt.Sprintf("protocol %d", e.DaemonProtocol)
+	if e.DaemonVersion != "" {
+		daemon = fmt.Sprintf("v%s (%s)", e.DaemonVersion, daemon)
+	}
+
+	return fmt.Sprintf("client/daemon version mismatch: client %s, daemon %s; restart the daemon with the same spm binary", client, daemon)
+}
+
+// newHello 创建当前版本的握手消息
+func newHello(token string) *HelloMsg {
+	return &HelloMsg{
+		Action:   ActionLog,
+		Protocol: ProtocolVersion,
+		Version:  utils.BinaryVersion,
+		Token:    token,
+	}
+}
+
+// acceptHello 检查客户端的握手消息并生成应答
+func acceptHello(hello *HelloMsg) *HelloReply {
+	reply := &HelloReply{
+		Protocol: ProtocolVersion,
+		Version:  utils.BinaryVersion,
+		Accepted: hello.Protocol == ProtocolVersion,
+	}
+
+	if !reply.Accepted {
+		reply.Message = (&VersionMismatchError{
+			ClientProtocol: hello.Protocol,
+			DaemonProtocol: ProtocolVersion,
+			ClientVersion:  hello.Version,
+			DaemonVersion:  utils.BinaryVersion,
+		}).Error()
+	}
+
+	return reply
+}
+
+// isLegacyFrame 判断消息是否为旧版协议按数组编码的消息
+//
+// 新版协议的所有消息都编码为 msgpack map，旧版为 msgpack array
+func isLegacyFrame(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	b := data[0]
+	return b&0xf0 == 0x90 || b == 0xdc || b == 0xdd
+}
+
+// legacyResponseMsg 旧版协议的响应结构，仅用于向旧版客户端返回版本不一致的错误
+type legacyResponseMsg struct {
+	Code      int
+	Message   string
+	Processes []*ProcInfo
+}
+
+// encodeLegacyError 按旧版协议编码错误响应，使旧版客户端也能显示错误原因
+func encodeLegacyError(code int, message string) ([]byte, error) {
+	var buf []byte
+	var mh codec.MsgpackHandle
+
+	mh.StructToArray = true
+
+	encoder := codec.NewEncoderBytes(&buf, &mh)
+	err := encoder.Encode(&legacyResponseMsg{Code: code, Message: message})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
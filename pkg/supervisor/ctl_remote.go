@@ -0,0 +1,170 @@
+// Package supervisor 提供控制协议的 TCP/TLS 远程监听
+package supervisor
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+
+	"spm/pkg/config"
+	"spm/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrUnauthorized 远程连接没有提交令牌或令牌无效
+var ErrUnauthorized = errors.New("authentication required: missing or invalid token")
+
+// remoteToken 解析后的远程控制令牌
+type remoteToken struct {
+	name     string
+	token    []byte
+	allowAll bool // 配置中没有列出操作，允许全部操作
+	actions  []string
+}
+
+// remoteAuth 按握手时提交的令牌检查远程连接的权限
+type remoteAuth struct {
+	tokens []*remoteToken
+}
+
+// newRemoteAuth 解析 spm.yml 中的远程控制令牌
+//
+// 令牌为空时返回 *config.ConfigError；未知的操作名称记录警告并忽略
+func newRemoteAuth(cfg config.Remote, log *zap.SugaredLogger) (*remoteAuth, error) {
+	ra := &remoteAuth{}
+
+	for i, t := range cfg.Tokens {
+		if t.Token == "" {
+			return nil, &config.ConfigError{
+				Path: fmt.Sprintf("remote.tokens[%d]", i),
+				Op:   "parse",
+				Err:  errors.New("token is empty"),
+			}
+		}
+
+		rt := &remoteToken{name: t.Name, token: []byte(t.Token), allowAll: len(t.Actions) == 0}
+		if rt.name == "" {
+			rt.name = fmt.Sprintf("#%d", i)
+		}
+
+		for _, action := range t.Actions {
+			if action != "*" && !slices.Contains(aclActions, action) {
+				log.Warnf("remote.tokens[%d]: unknown action %q", i, action)
+				continue
+			}
+			rt.actions = append(rt.actions, action)
+		}
+
+		ra.tokens = append(ra.tokens, rt)
+	}
+
+	return ra, nil
+}
+
+// check 判断令牌是否可以执行操作
+//
+// 没有配置令牌时只依靠 mTLS 认证，客户端证书已在 TLS 握手时验证，允许全部操作。
+// 令牌无效时返回 ErrUnauthorized，令牌不允许该操作时返回 *PermissionError
+func (ra *remoteAuth) check(token, action string) error {
+	if len(ra.tokens) == 0 {
+		return nil
+	}
+
+	// 逐个比较所有令牌，耗时与令牌在列表中的位置无关
+	var matched *remoteToken
+	for _, t := range ra.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), t.token) == 1 {
+			matched = t
+		}
+	}
+
+	if matched == nil {
+		return ErrUnauthorized
+	}
+
+	if matched.allowAll || slices.Contains(matched.actions, "*") || slices.Contains(matched.actions, action) {
+		return nil
+	}
+
+	return &PermissionError{Peer: "token " + matched.name, Action: action}
+}
+
+// serverTLSConfig 根据 remote 配置创建服务端的 TLS 配置
+//
+// 必须配置服务端证书；设置 clientCA 时要求并验证客户端证书。
+// 既没有令牌也没有 clientCA 时任何人都可以控制守护进程，返回 *config.ConfigError
+func serverTLSConfig(cfg config.Remote) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, &config.ConfigError{Path: "remote", Op: "validate", Err: errors.New("certFile and keyFile are required")}
+	}
+
+	if len(cfg.Tokens) == 0 && cfg.ClientCA == "" {
+		return nil, &config.ConfigError{Path: "remote", Op: "validate", Err: errors.New("no authentication configured, set tokens or clientCA")}
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, &config.ConfigError{Path: cfg.CertFile, Op: "load", Err: err}
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if cfg.ClientCA != "" {
+		pool, err := loadCertPool(cfg.ClientCA)
+		if err != nil {
+			return nil, &config.ConfigError{Path: cfg.ClientCA, Op: "load", Err: err}
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
+
+// StartRemoteServer 按 spm.yml 的 remote 配置启动 TCP/TLS 控制监听
+//
+// 未启用或配置错误时记录日志并返回，不影响 Unix 套接字的控制服务。
+// 监听在后台运行，与 Unix 套接字的控制服务一起在收到关闭通知后退出
+func StartRemoteServer(s *Supervisor) {
+	cfg := config.GetConfig().Remote
+	if !cfg.Enabled {
+		return
+	}
+
+	log := logger.Logging("spm-daemon")
+
+	tlsCfg, err := serverTLSConfig(cfg)
+	if err != nil {
+		log.Errorf("Remote control is disabled: %v", err)
+		return
+	}
+
+	auth, err := newRemoteAuth(cfg, log)
+	if err != nil {
+		log.Errorf("Remote control is disabled: %v", err)
+		return
+	}
+
+	sock, err := tls.Listen("tcp", cfg.Listen, tlsCfg)
+	if err != nil {
+		log.Errorf("Remote control is disabled: %v", err)
+		return
+	}
+
+	server := &spmServer{
+		sv:     s,
+		sock:   sock,
+		remote: auth,
+		logger: log,
+	}
+
+	log.Infof("Remote control is listening on %s", sock.Addr())
+
+	go server.Listen()
+}
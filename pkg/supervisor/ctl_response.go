@@ -1,5 +1,7 @@
 package supervisor
 
+import "strings"
+
 type ResponseCtl int
 
 const (
@@ -10,15 +12,135 @@ const (
 )
 
 type ProcInfo struct {
-	Pid     int          `msgpack:"pid"`
-	Name    string       `msgpack:"name"`
-	StartAt int64        `msgpack:"start_at"`
-	StopAt  int64        `msgpack:"stop_at"`
-	Status  ProcessState `msgpack:"status"`
+	Pid      int          `codec:"pid" json:"pid"`
+	Name     string       `codec:"name" json:"name"`
+	StartAt  int64        `codec:"start_at" json:"start_at"`
+	StopAt   int64        `codec:"stop_at" json:"stop_at"`
+	Status   ProcessState `codec:"status" json:"status"`
+	Restarts int          `codec:"restarts" json:"restarts"` // 第一次启动之后又启动的次数
+	Detail   *ProcDetail  `codec:"detail,omitempty" json:"detail,omitempty"`
+	Job      *JobInfo     `codec:"job,omitempty" json:"job,omitempty"` // 配置了 schedule 的定时任务
+	Run      *RunInfo     `codec:"run,omitempty" json:"run,omitempty"` // 由 spm run 注册的临时进程
+}
+
+// ProcDetail 进程的配置和运行参数，只在 status --verbose 时返回
+type ProcDetail struct {
+	Command string            `codec:"command" json:"command"`
+	Root    string            `codec:"root" json:"root"`
+	User    string            `codec:"user,omitempty" json:"user,omitempty"`
+	Rlimits map[string]string `codec:"rlimits,omitempty" json:"rlimits,omitempty"`
+	Sched   map[string]string `codec:"sched,omitempty" json:"sched,omitempty"` // nice、ionice、oomScoreAdj、cpuAffinity
+	Cgroup  string            `codec:"cgroup,omitempty" json:"cgroup,omitempty"`
+	Limits  map[string]string `codec:"limits,omitempty" json:"limits,omitempty"` // 叶子 cgroup 的资源限制
+	Usage   *ProcUsage        `codec:"usage,omitempty" json:"usage,omitempty"`
+}
+
+// newProcDetail 生成进程的详细信息，资源限制和调度选项在进程运行时取实际生效的值，运行中的进程附带 cgroup 路径和资源占用
+func newProcDetail(p *Process) *ProcDetail {
+	p.mu.Lock()
+	opts := p.Options
+	pid := p.Pid
+	if p.State != processRunning {
+		pid = -1
+	}
+	p.mu.Unlock()
+
+	user := opts.User
+	if opts.Group != "" {
+		user += ":" + opts.Group
+	}
+
+	detail := &ProcDetail{
+		Command: strings.Join(opts.cmd, " "),
+		Root:    opts.Root,
+		User:    user,
+		Rlimits: effectiveRlimits(pid, opts.rlimits),
+		Sched:   effectiveSched(pid, opts.sched),
+		Limits:  opts.cgroup,
+	}
+
+	if pid > 0 {
+		detail.Cgroup = p.cgroupPath()
+		detail.Usage = p.Usage()
+	}
+
+	return detail
+}
+
+// newProcInfo 根据进程实例生成响应中的进程信息，name 为空时使用进程全名
+func newProcInfo(p *Process, name string) *ProcInfo {
+	if name == "" {
+		name = p.FullName
+	}
+
+	info := &ProcInfo{
+		Pid:      p.Pid,
+		Name:     name,
+		StartAt:  p.StartAt.UnixMilli(),
+		StopAt:   p.StopAt.UnixMilli(),
+		Status:   p.State,
+		Restarts: max(p.starts-1, 0),
+		Job:      p.jobInfo(),
+		Run:      p.runInfo(),
+	}
+
+	if p.isArmed() && p.State != processRunning && p.State != processStopping {
+		info.Status = processScheduled
+	}
+
+	return info
+}
+
+// IsNotFound 判断进程是否不存在
+func (pi *ProcInfo) IsNotFound() bool {
+	return pi.Status == processNotfound
+}
+
+// ChangeType 描述 reload 时单个进程的变更类型
+type ChangeType string
+
+const (
+	ChangeAdded     ChangeType = "added"
+	ChangeChanged   ChangeType = "changed"
+	ChangeRemoved   ChangeType = "removed"
+	ChangeUnchanged ChangeType = "unchanged"
+)
+
+// ReloadAction 描述 reload 时对单个进程执行的操作
+type ReloadAction string
+
+const (
+	ReloadStart    ReloadAction = "start"    // 新增进程，项目中已有运行的进程时启动
+	ReloadRegister ReloadAction = "register" // 新增进程，仅注册不启动
+	ReloadRestart  ReloadAction = "restart"  // 配置变化且运行中，重启
+	ReloadUpdate   ReloadAction = "update"   // 配置变化但未运行，仅更新配置
+	ReloadStop     ReloadAction = "stop"     // 已移除且运行中，停止并注销
+	ReloadRemove   ReloadAction = "remove"   // 已移除且未运行，直接注销
+	ReloadNone     ReloadAction = "none"     // 配置未变化
+)
+
+// FieldDiff 描述单个配置项的新旧值
+type FieldDiff struct {
+	Field string `codec:"field" json:"field"`
+	Old   string `codec:"old" json:"old"`
+	New   string `codec:"new" json:"new"`
+}
+
+// ProcChange 描述 reload 时单个进程的配置差异及处理结果
+type ProcChange struct {
+	Name   string       `codec:"name" json:"name"`
+	Change ChangeType   `codec:"change" json:"change"`
+	Action ReloadAction `codec:"action" json:"action"`
+	Fields []*FieldDiff `codec:"fields" json:"fields,omitempty"`
+	Pid    int          `codec:"pid" json:"pid"`
+	Status ProcessState `codec:"status" json:"status"`
+
+	proc *Process
 }
 
 type ResponseMsg struct {
-	Code      int         `msgpack:"code"`
-	Message   string      `msgpack:"message"`
-	Processes []*ProcInfo `msgpack:"processes"`
+	Code      int           `codec:"code" json:"code"`
+	Message   string        `codec:"message" json:"message"`
+	Processes []*ProcInfo   `codec:"processes" json:"processes,omitempty"`
+	Changes   []*ProcChange `codec:"changes" json:"changes,omitempty"`
 }
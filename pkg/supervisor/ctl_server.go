@@ -1,7 +1,10 @@
 package supervisor
 
 import (
+	"errors"
 	"net"
+	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 
@@ -11,46 +14,83 @@ import (
 )
 
 type spmServer struct {
-	sv     *Supervisor
-	sock   net.Listener
-	logger *zap.SugaredLogger
+	sv      *Supervisor
+	sock    net.Listener
+	acl     *accessControl
+	remote  *remoteAuth // 不为 nil 时为 TCP/TLS 监听，按令牌检查权限
+	closing atomic.Bool
+	logger  *zap.SugaredLogger
 }
 
+// Listen 循环接受控制连接，每个连接在独立的 goroutine 中处理
+//
+// Unix 套接字的每个连接都通过 SO_PEERCRED 获取对端的 UID 和 GID，按 spm.yml 的 access 规则检查权限，
+// 无法获取凭据的连接直接关闭；TCP/TLS 连接按握手时提交的令牌检查权限
+//
+// utils.FinishChan 关闭后关闭监听套接字，使阻塞中的 Accept 立即返回，
+// 而不是等到下一个连接到来时才退出
 func (s *spmServer) Listen() {
 	defer func() {
 		_ = s.sock.Close()
-		close(utils.FinishChan)
 	}()
 
-SERVER:
+	go func() {
+		<-utils.FinishChan
+		s.closing.Store(true)
+		_ = s.sock.Close()
+	}()
+
 	for {
-		select {
-		case <-utils.FinishChan:
-			break SERVER
-		default:
-			{
-				conn, err := s.sock.Accept()
-				if err != nil {
-					s.logger.Error(err)
-					continue
-				}
-
-				session := NewSession(s.sv, conn)
-				go func(se *SpmSession) {
-					result := se.Handle()
-					if result == ResponseShutdown {
-						utils.FinishChan <- struct{}{}
-					}
-				}(session)
+		conn, err := s.sock.Accept()
+		if err != nil {
+			if s.closing.Load() || errors.Is(err, net.ErrClosed) {
+				break
 			}
+			s.logger.Error(err)
+			continue
+		}
+
+		var session *SpmSession
+		if s.remote != nil {
+			session = newRemoteSession(s.sv, conn, s.remote)
+		} else {
+			// 通过 SO_PEERCRED 确认对端身份，具体操作的权限在读取请求后检查
+			cred, err := getPeerCred(conn)
+			if err != nil {
+				s.logger.Errorf("Cannot get peer credentials: %v", err)
+				_ = conn.Close()
+				continue
+			}
+
+			session = NewSession(s.sv, conn, s.acl, cred)
 		}
+
+		go func(se *SpmSession) {
+			result := se.Handle()
+			if result == ResponseShutdown {
+				notifyFinish()
+			}
+		}(session)
 	}
 
-	s.logger.Info("Supervisor server is stopped")
+	s.logger.Infof("Supervisor server on %s is stopped", s.sock.Addr())
+}
+
+// finishOnce 保证 utils.FinishChan 只关闭一次
+var finishOnce sync.Once
+
+// notifyFinish 通过关闭 utils.FinishChan 通知所有控制服务器退出，可以重复调用
+func notifyFinish() {
+	finishOnce.Do(func() {
+		close(utils.FinishChan)
+	})
 }
 
 func StartServer(s *Supervisor) {
-	socket, err := net.Listen("unix", config.GetConfig().Socket)
+	cfg := config.GetConfig()
+
+	// 权限设置失败时不监听，不能让套接字以默认权限对外开放
+	socket, err := listenUnix(cfg.Socket, cfg.Access)
 	if err != nil {
 		panic(err)
 	}
@@ -60,6 +100,7 @@ func StartServer(s *Supervisor) {
 		sock:   socket,
 		logger: logger.Logging("spm-daemon"),
 	}
+	server.acl = newAccessControl(cfg.Access, server.logger)
 
 	server.Listen()
 }
@@ -2,13 +2,12 @@
 package supervisor
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
-	"os/exec"
-	"path/filepath"
-	"strconv"
+	"os"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -21,46 +20,55 @@ import (
 	"go.uber.org/zap"
 )
 
-type spmSocket struct {
-	conn net.Conn
+type SpmSession struct {
+	sv        *Supervisor
+	codec     *frameCodec
+	reqID     uint64
+	acl       *accessControl
+	cred      *peerCred
+	remote    *remoteAuth
+	token     string // 远程连接在握手时提交的令牌
+	peer      string // 日志中显示的对端描述
+	transport string // 监控指标中的请求来源
+	logger    *zap.SugaredLogger
 }
 
-func (s *spmSocket) Recv(l uint64) ([]byte, error) {
-	buf := make([]byte, l)
-	n, err := s.conn.Read(buf)
-	if err != nil {
-		return nil, err
-	}
-	if n == 0 {
-		return buf, io.EOF
-	} else {
-		return buf, nil
+func NewSession(s *Supervisor, c net.Conn, acl *accessControl, cred *peerCred) *SpmSession {
+	return &SpmSession{
+		sv:        s,
+		codec:     newFrameCodec(c),
+		acl:       acl,
+		cred:      cred,
+		peer:      fmt.Sprintf("pid %d", cred.pid),
+		transport: "socket",
+		logger:    logger.Logging("spm-serv"),
 	}
 }
 
-func (s *spmSocket) Send(v []byte) error {
-	_, e := s.conn.Write(v)
-	return e
+// newRemoteSession 创建 TCP/TLS 连接的会话，按握手时提交的令牌检查权限
+func newRemoteSession(s *Supervisor, c net.Conn, auth *remoteAuth) *SpmSession {
+	return &SpmSession{
+		sv:        s,
+		codec:     newFrameCodec(c),
+		remote:    auth,
+		peer:      c.RemoteAddr().String(),
+		transport: "tcp",
+		logger:    logger.Logging("spm-serv"),
+	}
 }
 
-func (s *spmSocket) Close() error {
-	return s.conn.Close()
-}
+// authorize 检查当前连接是否可以执行操作
+func (se *SpmSession) authorize(action string) error {
+	if se.remote != nil {
+		return se.remote.check(se.token, action)
+	}
 
-type SpmSession struct {
-	sv     *Supervisor
-	sock   *spmSocket
-	logger *zap.SugaredLogger
+	return se.acl.check(se.cred, action)
 }
 
-func NewSession(s *Supervisor, c net.Conn) *SpmSession {
-	return &SpmSession{
-		sv: s,
-		sock: &spmSocket{
-			conn: c,
-		},
-		logger: logger.Logging("spm-serv"),
-	}
+// fullAccess 判断对端是否为守护进程的运行用户或 root，远程连接和 HTTP 请求不是
+func (se *SpmSession) fullAccess() bool {
+	return se.remote == nil && se.acl != nil && se.acl.isOwner(se.cred)
 }
 
 // errorResponse 创建错误响应消息的辅助函数
@@ -76,7 +84,15 @@ func NewSession(s *Supervisor, c net.Conn) *SpmSession {
 //
 // 功能：
 //  1. 记录错误日志
-//  2. 创建标准的500错误响应
+//  2. 根据错误类型创建对应状态码的错误响应
+//
+// 状态码：
+//   - 401: 远程连接没有提交令牌或令牌无效（ErrUnauthorized）
+//   - 403: 对端用户没有执行操作的权限（*PermissionError）
+//   - 422: 配置错误（*config.ConfigError）
+//   - 413: 请求消息超过 MaxFrameSize
+//   - 404: 文件或目录不存在
+//   - 500: 其他错误
 //
 // 使用示例：
 //
@@ -87,11 +103,33 @@ func NewSession(s *Supervisor, c net.Conn) *SpmSession {
 func (se *SpmSession) errorResponse(err error) (*ResponseMsg, ResponseCtl) {
 	se.logger.Error(err)
 	return &ResponseMsg{
-		Code:    500,
+		Code:    errorCode(err),
 		Message: err.Error(),
 	}, ResponseMsgErr
 }
 
+// errorCode 根据错误类型返回响应状态码
+func errorCode(err error) int {
+	var cfgErr *config.ConfigError
+
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return 401
+	case isPermissionError(err):
+		return 403
+	case errors.As(err, &cfgErr):
+		return 422
+	case errors.Is(err, ErrFrameTooLarge):
+		return 413
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, errProcessNotFound):
+		return 404
+	case errors.Is(err, errProcessNotAdhoc):
+		return 409
+	default:
+		return 500
+	}
+}
+
 // sendResponse 发送响应消息到客户端
 //
 // 参数：
@@ -105,26 +143,11 @@ func (se *SpmSession) errorResponse(err error) (*ResponseMsg, ResponseCtl) {
 //
 // 功能：
 //  1. 编码响应消息
-//  2. 发送消息长度
-//  3. 发送消息内容
-//  4. 统一处理发送过程中的错误
+//  2. 以请求的 request ID 发送响应帧
+//  3. 统一处理发送过程中的错误
 func (se *SpmSession) sendResponse(res *ResponseMsg, result ResponseCtl) ResponseCtl {
-	buf, err := encodeData(res)
-	if err != nil {
-		se.logger.Error(err)
-		return ResponseMsgErr
-	}
-
-	size := make([]byte, strconv.IntSize)
-	binary.BigEndian.PutUint64(size, uint64(len(buf)))
-
-	if err = se.sock.Send(size); err != nil {
-		se.logger.Error(err)
-		return ResponseMsgErr
-	}
-
-	if err = se.sock.Send(buf); err != nil {
-		se.logger.Error(err)
+	if err := writeMsg(se.codec, se.reqID, res); err != nil {
+		se.logger.Errorf("request %d: %v", se.reqID, err)
 		return ResponseMsgErr
 	}
 
@@ -133,21 +156,18 @@ func (se *SpmSession) sendResponse(res *ResponseMsg, result ResponseCtl) Respons
 
 func (se *SpmSession) Handle() ResponseCtl {
 	defer func() {
-		_ = se.sock.Close()
+		_ = se.codec.Close()
 	}()
 
 	// 服务器端处理收到的指令
 
-	// 先接收消息的字节数组长度
-	buf, err := se.sock.Recv(strconv.IntSize)
-	if err != nil {
-		res, result := se.errorResponse(err)
-		return se.sendResponse(res, result)
+	// 先完成协议版本握手
+	if !se.handshake() {
+		return ResponseMsgErr
 	}
 
-	// 根据长度再接受ActionMsg消息
-	msgLen := binary.BigEndian.Uint64(buf)
-	buf, err = se.sock.Recv(msgLen)
+	// 握手成功后再接受ActionMsg消息
+	_, buf, err := se.codec.ReadFrame()
 	if err != nil {
 		res, result := se.errorResponse(err)
 		return se.sendResponse(res, result)
@@ -159,10 +179,23 @@ func (se *SpmSession) Handle() ResponseCtl {
 		return se.sendResponse(res, result)
 	}
 
+	se.logger.Debugf("request %d: action %s", se.reqID, msg.Action)
+
 	// 处理业务逻辑
 	var res *ResponseMsg
 	var result ResponseCtl
 
+	start := time.Now()
+	defer func() {
+		observeRequest(se.transport, msg.Action.String(), res.Code, time.Since(start))
+	}()
+
+	if err := se.authorize(aclAction(msg.Action)); err != nil {
+		se.logger.Warnf("request %d from %s: %v", se.reqID, se.peer, err)
+		res, result = &ResponseMsg{Code: errorCode(err), Message: err.Error()}, ResponseMsgErr
+		return se.sendResponse(res, result)
+	}
+
 	switch msg.Action {
 	case ActionKill, ActionShutdown:
 		{
@@ -186,14 +219,22 @@ func (se *SpmSession) Handle() ResponseCtl {
 			}()
 		}
 	case ActionLog:
-		res = &ResponseMsg{
-			Code:    404,
-			Message: "Feature not implemented",
-		}
-		result = ResponseMsgErr
+		// 日志流在 streamLogs 中发送应答和后续的日志行
+		res, result = se.streamLogs(msg)
+		return result
+	case ActionAttach:
+		// 连接进程的伪终端，在 attachConsole 中发送应答和后续的输出
+		res, result = se.attachConsole(msg)
+		return result
+	case ActionSignal:
+		res = se.doSignal(msg)
+		result = ResponseNormal
 	case ActionRun:
 		res = se.doRun(msg)
 		result = ResponseNormal
+	case ActionRemove:
+		res = se.doRemove(msg)
+		result = ResponseNormal
 	case ActionReload:
 		res = se.doReload(msg)
 		result = ResponseReload
@@ -205,57 +246,148 @@ func (se *SpmSession) Handle() ResponseCtl {
 	return se.sendResponse(res, result)
 }
 
+// handshake 接收客户端的握手消息并应答，协议版本一致时返回 true
+//
+// 旧版客户端不发送握手消息，直接发送按数组编码的 ActionMsg，
+// 此时按旧版协议返回版本不一致的错误，让旧版客户端也能看到原因
+func (se *SpmSession) handshake() bool {
+	id, buf, err := se.codec.ReadFrame()
+	se.reqID = id
+	if err != nil {
+		if errors.Is(err, ErrFrameTooLarge) {
+			res, result := se.errorResponse(err)
+			se.sendResponse(res, result)
+		} else if !errors.Is(err, io.EOF) {
+			se.logger.Error(err)
+		}
+		return false
+	}
+
+	if isLegacyFrame(buf) {
+		mismatch := &VersionMismatchError{
+			ClientProtocol: 1,
+			DaemonProtocol: ProtocolVersion,
+			DaemonVersion:  utils.BinaryVersion,
+		}
+		se.logger.Warn(mismatch)
+
+		data, err := encodeLegacyError(426, mismatch.Error())
+		if err == nil {
+			err = se.codec.WriteFrame(0, data)
+		}
+		if err != nil {
+			se.logger.Error(err)
+		}
+		return false
+	}
+
+	hello, err := decodeData[HelloMsg](buf)
+	if err != nil {
+		se.logger.Error(err)
+		return false
+	}
+	se.token = hello.Token
+
+	reply := acceptHello(hello)
+	if !reply.Accepted {
+		se.logger.Warn(reply.Message)
+	}
+
+	if err := writeMsg(se.codec, se.reqID, reply); err != nil {
+		se.logger.Error(err)
+		return false
+	}
+
+	return reply.Accepted
+}
+
 func (se *SpmSession) doReload(msg *ActionMsg) *ResponseMsg {
-	changedTotal := make([]*Process, 0)
+	changesTotal := make([]*ProcChange, 0)
 	procOpts := make([]*ProcfileOption, 0)
 
 	if msg.Projects != "" {
-		if strings.Contains(msg.Projects, ";") {
-			projects := strings.Split(msg.Projects, ";")
-			for _, p := range projects {
-				procOpts = append(procOpts, &ProcfileOption{AppName: p})
+		// 按项目名重载时，从项目注册时记录的目录重新读取配置
+		for _, name := range strings.Split(msg.Projects, ";") {
+			proj := se.sv.projectTable.Get(name)
+			if proj == nil {
+				se.logger.Errorf("Cannot find project %s.", name)
+				return &ResponseMsg{
+					Code:    404,
+					Message: fmt.Sprintf("Project %s not found", name),
+				}
 			}
-		} else {
-			procOpts = append(procOpts, &ProcfileOption{AppName: msg.Projects})
+
+			opt, err := LoadProcfileOption(proj.WorkDir, proj.Procfile)
+			if err != nil {
+				res, _ := se.errorResponse(err)
+				return res
+			}
+			procOpts = append(procOpts, opt)
 		}
+	} else if msg.WorkDir != "" && msg.Procfile != "" {
+		opt, err := LoadProcfileOption(msg.WorkDir, msg.Procfile)
+		if err != nil {
+			res, _ := se.errorResponse(err)
+			return res
+		}
+		procOpts = append(procOpts, opt)
 	} else {
-		if msg.WorkDir != "" && msg.Procfile != "" {
-			opt, err := LoadProcfileOption(msg.WorkDir, msg.Procfile)
-			if err != nil {
-				se.logger.Error(err)
-			} else {
-				procOpts = append(procOpts, opt)
+		return &ResponseMsg{
+			Code:    400,
+			Message: "No project to reload",
+		}
+	}
+
+	// dry-run 只计算变更计划，不触碰任何运行中的进程
+	if msg.DryRun {
+		for _, opt := range procOpts {
+			changes := se.sv.PlanReload(opt)
+			if changes == nil {
+				return &ResponseMsg{
+					Code:    404,
+					Message: fmt.Sprintf("Project %s not found", opt.AppName),
+				}
 			}
+			changesTotal = append(changesTotal, changes...)
+		}
+
+		return &ResponseMsg{
+			Code:    200,
+			Message: summarizeChanges(changesTotal),
+			Changes: changesTotal,
 		}
 	}
 
 	for _, opt := range procOpts {
-		_, changed := se.sv.UpdateApp(false, opt)
-		if changed == nil {
+		proj, changes := se.sv.UpdateApp(false, opt)
+		if proj == nil {
 			se.logger.Errorf("Cannot find project %s.", opt.AppName)
 			return &ResponseMsg{
-				Code:    500,
-				Message: "Reload failed",
+				Code:    404,
+				Message: fmt.Sprintf("Project %s not found", opt.AppName),
 			}
 		} else {
-			changedTotal = append(changedTotal, changed...)
+			changesTotal = append(changesTotal, changes...)
 		}
 	}
 
 	return &ResponseMsg{
 		Code:      200,
 		Message:   "Reload successfully",
-		Processes: se.sv.Reload(changedTotal),
+		Processes: se.sv.Reload(changesTotal),
+		Changes:   changesTotal,
 	}
 }
 
 func (se *SpmSession) doRun(msg *ActionMsg) *ResponseMsg {
-	var exe string
-	var args = make([]string, 0)
-
-	exe = msg.CmdLine[0]
+	if err := validRunName(msg.Name); err != nil {
+		return &ResponseMsg{
+			Code:    400,
+			Message: err.Error(),
+		}
+	}
 
-	exePath, err := exec.LookPath(exe)
+	opt, err := newRunOption(msg)
 	if err != nil {
 		return &ResponseMsg{
 			Code:    500,
@@ -263,12 +395,6 @@ func (se *SpmSession) doRun(msg *ActionMsg) *ResponseMsg {
 		}
 	}
 
-	args = append(args, exePath)
-
-	if len(msg.CmdLine) > 1 {
-		args = append(args, msg.CmdLine[1:]...)
-	}
-
 	appName, err := GetAppName(msg.WorkDir)
 	if err != nil {
 		return &ResponseMsg{
@@ -277,36 +403,116 @@ func (se *SpmSession) doRun(msg *ActionMsg) *ResponseMsg {
 		}
 	}
 
-	procName := filepath.Base(exePath)
-
 	// 手工写项目的配置参数，用于手动将执行的命令注册为托管的进程
 	procOpts := &ProcfileOption{
-		AppName:   appName,
-		WorkDir:   msg.WorkDir,
-		Procfile:  msg.Procfile,
-		Env:       make(map[string]string),
-		Processes: make(map[string]*ProcessOption),
+		AppName:  appName,
+		WorkDir:  msg.WorkDir,
+		Procfile: msg.Procfile,
+		Env:      make(map[string]string),
+	}
+
+	p, err := se.sv.Run(procOpts, msg.Name, opt)
+	if err != nil {
+		return &ResponseMsg{
+			Code:    409,
+			Message: err.Error(),
+		}
+	}
+
+	return &ResponseMsg{
+		Code:      200,
+		Message:   actionResponse[msg.Action],
+		Processes: []*ProcInfo{newProcInfo(p, "")},
+	}
+}
+
+// doSignal 向进程发送 msg.Signal 指定的信号，进程名不是完整进程名时属于 WorkDir 对应的项目
+func (se *SpmSession) doSignal(msg *ActionMsg) *ResponseMsg {
+	sig, err := ParseSignal(msg.Signal)
+	if err != nil || msg.Signal == "" {
+		return &ResponseMsg{
+			Code:    400,
+			Message: fmt.Sprintf("Invalid signal %q", msg.Signal),
+		}
+	}
+
+	if msg.Processes == "" || msg.Processes == "*" {
+		return &ResponseMsg{
+			Code:    400,
+			Message: "Process names are required",
+		}
 	}
 
-	procOpts.Processes[procName] = &ProcessOption{
-		Root:       msg.WorkDir,
-		PidRoot:    config.GetRuntimeDir("/var"),
-		LogRoot:    config.GetRuntimeDir("/var"),
-		Env:        make(map[string]string),
-		StopSignal: "TERM",
-		NumProcs:   1,
+	infos := make([]*ProcInfo, 0)
+	for _, name := range strings.Split(msg.Processes, ";") {
+		if !strings.Contains(name, "::") {
+			appName, err := GetAppName(msg.WorkDir)
+			if err != nil {
+				res, _ := se.errorResponse(err)
+				return res
+			}
+			name = fmt.Sprintf("%s::%s", appName, name)
+		}
+
+		p, err := se.sv.Signal(name, sig)
+		if p.State == processNotfound {
+			return &ResponseMsg{
+				Code:      404,
+				Message:   fmt.Sprintf("Process not found: %s", name),
+				Processes: infos,
+			}
+		}
+		if err != nil {
+			return &ResponseMsg{
+				Code:      409,
+				Message:   err.Error(),
+				Processes: infos,
+			}
+		}
+
+		infos = append(infos, newProcInfo(p, name))
+	}
+
+	return &ResponseMsg{
+		Code:      200,
+		Message:   fmt.Sprintf("Sent %v to %d processes", sig, len(infos)),
+		Processes: infos,
+	}
+}
 
-		cmd: args,
+// doRemove 停止并注销 spm run 注册的临时进程，进程名不是完整进程名时属于 WorkDir 对应的项目
+func (se *SpmSession) doRemove(msg *ActionMsg) *ResponseMsg {
+	if msg.Processes == "" || msg.Processes == "*" {
+		return &ResponseMsg{
+			Code:    400,
+			Message: "Process names are required",
+		}
 	}
 
-	// 第一遍注册进程
-	_, _ = se.sv.UpdateApp(true, procOpts)
+	names := strings.Split(msg.Processes, ";")
+	for i, n := range names {
+		if strings.Contains(n, "::") {
+			continue
+		}
 
-	// 第二遍reload进程表
-	_, _ = se.sv.UpdateApp(false, procOpts)
+		appName, err := GetAppName(msg.WorkDir)
+		if err != nil {
+			res, _ := se.errorResponse(err)
+			return res
+		}
+		names[i] = fmt.Sprintf("%s::%s", appName, n)
+	}
 
-	// 运行单个的进程
-	infos := se.sv.BatchDo(ActionStart, procOpts, []string{fmt.Sprintf("%s::%s", appName, procName)})
+	procs, err := se.sv.Remove(names)
+	if err != nil {
+		res, _ := se.errorResponse(err)
+		return res
+	}
+
+	infos := make([]*ProcInfo, 0, len(procs))
+	for _, p := range procs {
+		infos = append(infos, newProcInfo(p, ""))
+	}
 
 	return &ResponseMsg{
 		Code:      200,
@@ -336,33 +542,60 @@ func (se *SpmSession) doAction(msg *ActionMsg) *ResponseMsg {
 			names := strings.Split(n, "::")
 			appName := names[0]
 
-			procMap[appName] = make([]string, 0)
 			procMap[appName] = append(procMap[appName], n)
 		} else {
 			localProcs = append(localProcs, n)
 		}
 	}
 
+	// 没有指定项目目录时（例如通过 --host 远程控制），"*" 表示所有项目的所有进程
+	if msg.WorkDir == "" && slices.Equal(localProcs, []string{"*"}) {
+		for name := range se.sv.projectTable.Iter() {
+			procMap[name] = []string{"*"}
+		}
+		localProcs = localProcs[:0]
+	}
+
 	var procOpts *ProcfileOption
-	var err error
 
 	if len(localProcs) > 0 {
-		procOpts, err = LoadProcfileOption(msg.WorkDir, msg.Procfile)
+		appName, err := GetAppName(msg.WorkDir)
 		if err != nil {
-			se.logger.Error(err)
-			return &ResponseMsg{
-				Code:    500,
-				Message: "Load procfile options failed.",
+			res, _ := se.errorResponse(err)
+			return res
+		}
+
+		// 只有守护进程的运行用户和 root 可以通过 start 读取客户端指定目录的配置并注册项目，
+		// 其他操作和其他用户只能操作已经注册的项目，避免借守护进程的身份读取文件或运行任意命令
+		if se.sv.projectTable.Get(appName) == nil {
+			switch {
+			case msg.Action != ActionStart:
+				return &ResponseMsg{
+					Code:    404,
+					Message: fmt.Sprintf("Project %s not found, start it with spm start first", appName),
+				}
+			case !se.fullAccess():
+				return &ResponseMsg{
+					Code:    403,
+					Message: fmt.Sprintf("permission denied: project %s is not registered, only the daemon user can start a new project", appName),
+				}
+			}
+
+			procOpts, err = LoadProcfileOption(msg.WorkDir, msg.Procfile)
+			if err != nil {
+				res, _ := se.errorResponse(err)
+				return res
 			}
+			appName = procOpts.AppName
 		}
 
 		if localProcs[0] != "*" {
 			for i, n := range localProcs {
-				localProcs[i] = fmt.Sprintf("%s::%s", procOpts.AppName, n)
+				localProcs[i] = fmt.Sprintf("%s::%s", appName, n)
 			}
 		}
 
-		procMap[procOpts.AppName] = localProcs
+		procMap[appName] = localProcs
 	}
 
 	for name, procs := range procMap {
@@ -370,6 +603,13 @@ func (se *SpmSession) doAction(msg *ActionMsg) *ResponseMsg {
 
 		proj := se.sv.projectTable.Get(name)
 		if proj == nil {
+			// 完整进程名中的项目未注册，也不是当前目录的项目
+			if procOpts == nil || procOpts.AppName != name {
+				for _, n := range procs {
+					infos = append(infos, newProcInfo(notFoundProc, n))
+				}
+				continue
+			}
 			opt = procOpts
 		} else {
 			opt = &ProcfileOption{AppName: name}
@@ -378,6 +618,39 @@ func (se *SpmSession) doAction(msg *ActionMsg) *ResponseMsg {
 		infos = append(infos, se.sv.BatchDo(msg.Action, opt, procs)...)
 	}
 
+	// start --watch 为启动的进程开启文件监控，stop 时关闭
+	if (msg.Action == ActionStart && msg.Watch) || msg.Action == ActionStop {
+		for _, info := range infos {
+			if p := se.sv.procTable.Get(info.Name); p != nil {
+				p.setWatch(msg.Action == ActionStart)
+			}
+		}
+	}
+
+	// status --verbose 附带进程的配置和运行参数
+	if msg.Verbose {
+		for _, info := range infos {
+			if p := se.sv.procTable.Get(info.Name); p != nil {
+				info.Detail = newProcDetail(p)
+			}
+		}
+	}
+
+	// 请求的进程全部不存在时返回 404，部分不存在时由各进程的状态体现
+	notFound := make([]string, 0)
+	for _, info := range infos {
+		if info.IsNotFound() {
+			notFound = append(notFound, info.Name)
+		}
+	}
+	if len(infos) > 0 && len(notFound) == len(infos) {
+		return &ResponseMsg{
+			Code:      404,
+			Message:   fmt.Sprintf("Process not found: %s", strings.Join(notFound, ", ")),
+			Processes: infos,
+		}
+	}
+
 	return &ResponseMsg{
 		Code:      200,
 		Message:   actionResponse[msg.Action],
@@ -385,12 +658,11 @@ func (se *SpmSession) doAction(msg *ActionMsg) *ResponseMsg {
 	}
 }
 
+// decodeData 解码按字段名编码的 msgpack 消息，未知字段会被忽略，缺失字段保持零值
 func decodeData[T any](data []byte) (*T, error) {
 	var msg = new(T)
 	var mh codec.MsgpackHandle
 
-	mh.StructToArray = true
-
 	decoder := codec.NewDecoderBytes(data, &mh)
 	err := decoder.Decode(msg)
 	if err != nil {
@@ -400,12 +672,11 @@ func decodeData[T any](data []byte) (*T, error) {
 	return msg, nil
 }
 
+// encodeData 把消息编码为 msgpack map，字段名取自 codec 标签
 func encodeData[T any](v *T) ([]byte, error) {
 	var buf []byte
 	var mh codec.MsgpackHandle
 
-	mh.StructToArray = true
-
 	encoder := codec.NewEncoderBytes(&buf, &mh)
 	err := encoder.Encode(v)
 	if err != nil {
@@ -44,7 +44,7 @@ func GetDaemon() *daemon.Context {
 //
 // 功能：
 //  1. 初始化守护进程（或前台模式）
-//  2. 启动 RPC 服务器
+//  2. 启动 RPC 服务器，按配置启动 HTTP/JSON API
 //  3. 监听系统信号
 //  4. 优雅关闭
 //
@@ -102,7 +102,14 @@ func (sv *Supervisor) Daemon() {
 
 	fmt.Printf("\033[1;33;40mSpm supervisor started at %s\033[0m\n\n", sv.StartedAt.Format(time.RFC3339))
 
+	if err := initCgroups(config.GetConfig().Cgroup, sv.logger); err != nil {
+		sv.logger.Errorf("Cannot set up cgroup, processes will run without resource control: %v", err)
+	}
+
 	go StartServer(sv)
+	StartRemoteServer(sv)
+
+	api := StartHTTPServer(sv)
 
 	sv.logger.Infof("Spm supervisor PID %d", sv.Pid)
 
@@ -110,13 +117,22 @@ func (sv *Supervisor) Daemon() {
 		go sv.AfterStart()
 	}
 
+	backgroundStop := make(chan struct{})
+	go sv.runWatchdog(backgroundStop)
+	go sv.runFileWatch(backgroundStop)
+
 	sig := <-utils.StopChan
+	close(backgroundStop)
 
 	switch sig {
 	case os.Interrupt, syscall.SIGTERM:
-		utils.FinishChan <- struct{}{}
+		if config.ForegroundFlag {
+			fmt.Printf("\n\033[1;33;40mReceived %v, stopping all processes\033[0m\n", sig)
+		}
+		notifyFinish()
 		sv.Shutdown()
 	}
+	api.Close()
 	close(utils.StopChan)
 
 	sv.logger.Info("Supervisor daemon stopped")
@@ -137,6 +153,11 @@ func (sv *Supervisor) Daemon() {
 //
 //	defer sv.Shutdown()  // 确保程序退出时调用
 func (sv *Supervisor) Shutdown() {
+	// 等待自动重启的临时进程不再启动
+	for _, p := range sv.procTable.Iter() {
+		p.cancelRestart()
+	}
+
 	_ = sv.StopAll("*")
 
 	pt := sv.procTable.Iter()
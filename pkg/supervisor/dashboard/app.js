@@ -0,0 +1,200 @@
+// spm dashboard: polls the HTTP API and reacts to the event stream.
+"use strict";
+
+const API = "api/v1";
+const REFRESH_MS = 3000;
+const MAX_LOG_LINES = 2000;
+
+const $ = (id) => document.getElementById(id);
+
+// Previous CPU samples, used to turn cumulative CPU seconds into a percentage.
+const cpuSamples = new Map();
+
+let refreshTimer = null;
+let logSource = null;
+
+function el(tag, attrs = {}, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs)) {
+    if (k === "class") node.className = v;
+    else if (k.startsWith("on")) node.addEventListener(k.slice(2), v);
+    else node.setAttribute(k, v);
+  }
+  for (const c of children) {
+    node.append(c instanceof Node ? c : document.createTextNode(c ?? ""));
+  }
+  return node;
+}
+
+function formatDuration(seconds) {
+  if (!seconds) return "";
+  seconds = Math.floor(seconds);
+  const d = Math.floor(seconds / 86400);
+  const h = Math.floor((seconds % 86400) / 3600);
+  const m = Math.floor((seconds % 3600) / 60);
+  const s = seconds % 60;
+  if (d) return `${d}d ${h}h`;
+  if (h) return `${h}h ${m}m`;
+  if (m) return `${m}m ${s}s`;
+  return `${s}s`;
+}
+
+function formatBytes(bytes) {
+  if (bytes == null) return "";
+  const units = ["B", "KiB", "MiB", "GiB"];
+  let i = 0;
+  while (bytes >= 1024 && i < units.length - 1) {
+    bytes /= 1024;
+    i++;
+  }
+  return `${bytes.toFixed(i ? 1 : 0)} ${units[i]}`;
+}
+
+function cpuPercent(proc) {
+  if (!proc.usage) {
+    cpuSamples.delete(proc.name);
+    return "";
+  }
+  const now = performance.now() / 1000;
+  const prev = cpuSamples.get(proc.name);
+  cpuSamples.set(proc.name, { pid: proc.pid, cpu: proc.usage.cpu_seconds, at: now });
+  if (!prev || prev.pid !== proc.pid || now <= prev.at) return "…";
+  const pct = ((proc.usage.cpu_seconds - prev.cpu) / (now - prev.at)) * 100;
+  return `${Math.max(pct, 0).toFixed(1)}%`;
+}
+
+async function request(method, path) {
+  const res = await fetch(`${API}/${path}`, { method });
+  const body = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(body.message || `${res.status} ${res.statusText}`);
+  return body;
+}
+
+async function runAction(button, path) {
+  button.disabled = true;
+  try {
+    await request("POST", path);
+  } catch (err) {
+    alert(err.message);
+  } finally {
+    button.disabled = false;
+    refresh();
+  }
+}
+
+function actionButtons(kind, name) {
+  const path = `${kind}/${encodeURIComponent(name)}`;
+  const buttons = ["start", "stop", "restart"].map((action) =>
+    el("button", { type: "button", onclick: (e) => runAction(e.target, `${path}/${action}`) }, action),
+  );
+  if (kind === "processes") {
+    buttons.push(el("button", { type: "button", onclick: () => openLogs(name) }, "logs"));
+  }
+  return buttons;
+}
+
+function renderProcess(proc) {
+  const shortName = proc.name.split("::").slice(1).join("::");
+  const usage = proc.usage || {};
+  return el(
+    "tr",
+    {},
+    el("td", {}, shortName),
+    el("td", {}, el("span", { class: `state ${proc.status}` }, proc.status)),
+    el("td", { class: "num" }, proc.pid > 0 ? String(proc.pid) : ""),
+    el("td", { class: "num" }, formatDuration(proc.uptime_seconds)),
+    el("td", { class: "num" }, cpuPercent(proc)),
+    el("td", { class: "num" }, formatBytes(usage.rss_bytes)),
+    el("td", { class: "num" }, usage.fds >= 0 ? String(usage.fds) : ""),
+    el("td", { class: "actions" }, ...actionButtons("processes", proc.name)),
+  );
+}
+
+function renderProject(project) {
+  const head = el(
+    "tr",
+    {},
+    ...["process", "state", "pid", "uptime", "cpu", "rss", "fds", ""].map((h) => el("th", {}, h)),
+  );
+  return el(
+    "section",
+    { class: "project" },
+    el(
+      "div",
+      { class: "project-header" },
+      el("h2", {}, project.name),
+      el("span", { class: "dir" }, project.work_dir),
+      el("span", { class: "actions" }, ...actionButtons("projects", project.name)),
+    ),
+    el("table", {}, el("thead", {}, head), el("tbody", {}, ...project.processes.map(renderProcess))),
+  );
+}
+
+async function refresh() {
+  clearTimeout(refreshTimer);
+  try {
+    const projects = await request("GET", "projects");
+    const main = $("projects");
+    main.replaceChildren(
+      ...(projects.length ? projects.map(renderProject) : [el("p", { class: "empty" }, "No projects registered.")]),
+    );
+    $("conn").textContent = `updated ${new Date().toLocaleTimeString()}`;
+    $("conn").classList.remove("down");
+  } catch (err) {
+    $("conn").textContent = `daemon unreachable: ${err.message}`;
+    $("conn").classList.add("down");
+  }
+  refreshTimer = setTimeout(refresh, REFRESH_MS);
+}
+
+function openLogs(name) {
+  closeLogs();
+  $("logs").hidden = false;
+  $("logs-title").textContent = name;
+  $("logs-body").replaceChildren();
+
+  logSource = new EventSource(`${API}/processes/${encodeURIComponent(name)}/logs?tail=200`);
+  logSource.addEventListener("log", (e) => {
+    const line = JSON.parse(e.data);
+    const body = $("logs-body");
+    body.append(el("div", { class: line.stream }, line.line));
+    while (body.childElementCount > MAX_LOG_LINES) body.firstElementChild.remove();
+    if ($("logs-follow").checked) body.scrollTop = body.scrollHeight;
+  });
+}
+
+function closeLogs() {
+  if (logSource) {
+    logSource.close();
+    logSource = null;
+  }
+  $("logs").hidden = true;
+}
+
+function watchEvents() {
+  // Refresh as soon as a process changes state instead of waiting for the next poll.
+  const events = new EventSource(`${API}/events`);
+  for (const type of ["started", "failed", "stopped", "exited", "reload"]) {
+    events.addEventListener(type, () => {
+      clearTimeout(refreshTimer);
+      refreshTimer = setTimeout(refresh, 200);
+    });
+  }
+}
+
+async function init() {
+  $("logs-close").addEventListener("click", closeLogs);
+  $("logs-clear").addEventListener("click", () => $("logs-body").replaceChildren());
+
+  try {
+    const v = await request("GET", "version");
+    $("daemon").textContent = `v${v.version} · pid ${v.pid} · up since ${new Date(v.started_at).toLocaleString()}`;
+  } catch (err) {
+    $("daemon").textContent = "";
+  }
+
+  refresh();
+  watchEvents();
+}
+
+init();
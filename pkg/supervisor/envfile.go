@@ -0,0 +1,178 @@
+// Package supervisor 提供 .env 文件解析功能
+package supervisor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// LoadEnvFiles 按顺序读取多个 .env 文件并合并结果
+//
+// 参数：
+//
+//	baseDir: 相对路径的基准目录
+//	files: .env 文件路径列表，后面的文件覆盖前面的同名变量
+//
+// 返回：
+//
+//	map[string]string: 合并后的环境变量
+//	error: 任一文件读取或解析失败时返回错误
+func LoadEnvFiles(baseDir string, files []string) (map[string]string, error) {
+	envs := make(map[string]string)
+
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(baseDir, f)
+		}
+
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := ParseDotenv(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+
+		for k, v := range parsed {
+			envs[k] = v
+		}
+	}
+
+	return envs, nil
+}
+
+// ParseDotenv 按 dotenv 语义解析文本内容
+//
+// 支持的语法：
+//   - 空行和 # 开头的注释行
+//   - 可选的 export 前缀
+//   - 单引号：原样保留内容，可跨行
+//   - 双引号：支持 \n \t \" \\ 转义，可跨行
+//   - 无引号：去掉首尾空白和行内 " #" 注释
+//
+// 错误信息只包含行号，不包含文件内容：错误会返回给客户端，不能借此读取守护进程才能访问的文件
+func ParseDotenv(content string) (map[string]string, error) {
+	envs := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '='", lineNo)
+		}
+
+		key = strings.TrimSpace(key)
+		if !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name", lineNo)
+		}
+
+		value = strings.TrimSpace(value)
+
+		if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			body := value[1:]
+			startLine := lineNo
+
+			// 引号未闭合时继续读取后续行，实现多行值
+			for !hasClosingQuote(body, quote) {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated quoted value", startLine)
+				}
+				lineNo++
+				body += "\n" + scanner.Text()
+			}
+
+			end := closingQuoteIndex(body, quote)
+			body = body[:end]
+
+			if quote == '"' {
+				body = unescapeDoubleQuoted(body)
+			}
+
+			envs[key] = body
+			continue
+		}
+
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			value = strings.TrimSpace(value[:idx])
+		}
+
+		envs[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return envs, nil
+}
+
+func hasClosingQuote(s string, quote byte) bool {
+	return closingQuoteIndex(s, quote) >= 0
+}
+
+// closingQuoteIndex 查找未被转义的结束引号位置，单引号内不处理转义
+func closingQuoteIndex(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func unescapeDoubleQuoted(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package supervisor
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{
+			name:    "plain",
+			content: "FOO=bar\nBAZ = qux \n",
+			want:    map[string]string{"FOO": "bar", "BAZ": "qux"},
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# comment\n\n  # indented\nFOO=bar\n",
+			want:    map[string]string{"FOO": "bar"},
+		},
+		{
+			name:    "export prefix",
+			content: "export FOO=bar\nexport  BAR=baz\n",
+			want:    map[string]string{"FOO": "bar", "BAR": "baz"},
+		},
+		{
+			name:    "key case preserved",
+			content: "Path=/bin\nhttp_proxy=http://proxy\n",
+			want:    map[string]string{"Path": "/bin", "http_proxy": "http://proxy"},
+		},
+		{
+			name:    "inline comment",
+			content: "FOO=bar # trailing\nURL=http://host/#anchor\n",
+			want:    map[string]string{"FOO": "bar", "URL": "http://host/#anchor"},
+		},
+		{
+			name:    "single quotes keep content",
+			content: `FOO='a \n $b # c'` + "\n",
+			want:    map[string]string{"FOO": `a \n $b # c`},
+		},
+		{
+			name:    "double quotes unescape",
+			content: `FOO="a\nb\t\"c\" \\ \$d"` + "\n",
+			want:    map[string]string{"FOO": "a\nb\t\"c\" \\ $d"},
+		},
+		{
+			name:    "double quotes keep hash",
+			content: `FOO="bar # not a comment"` + "\n",
+			want:    map[string]string{"FOO": "bar # not a comment"},
+		},
+		{
+			name:    "multiline double quoted",
+			content: "KEY=\"-----BEGIN-----\nline2\n-----END-----\"\nNEXT=1\n",
+			want:    map[string]string{"KEY": "-----BEGIN-----\nline2\n-----END-----", "NEXT": "1"},
+		},
+		{
+			name:    "multiline single quoted",
+			content: "KEY='a\nb'\n",
+			want:    map[string]string{"KEY": "a\nb"},
+		},
+		{
+			name:    "empty value",
+			content: "FOO=\nBAR=\"\"\n",
+			want:    map[string]string{"FOO": "", "BAR": ""},
+		},
+		{
+			name:    "later wins",
+			content: "FOO=1\nFOO=2\n",
+			want:    map[string]string{"FOO": "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDotenv(tt.content)
+			if err != nil {
+				t.Fatalf("ParseDotenv() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("ParseDotenv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "missing equals", content: "FOO\n"},
+		{name: "invalid name", content: "1FOO=bar\n"},
+		{name: "unterminated quote", content: "FOO=\"bar\nBAZ=1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDotenv(tt.content); err == nil {
+				t.Errorf("ParseDotenv(%q) expected error", tt.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Package supervisor 提供进程事件和日志的订阅功能
+package supervisor
+
+import (
+	"time"
+
+	"spm/pkg/pubsub"
+)
+
+// EventType 进程事件类型
+type EventType string
+
+const (
+	EventStarted EventType = "started" // 进程启动成功
+	EventFailed  EventType = "failed"  // 进程启动失败
+	EventStopped EventType = "stopped" // 进程被 spm 停止
+	EventExited  EventType = "exited"  // 进程退出（包括被停止和自行退出）
+	EventReload  EventType = "reload"  // 项目配置重载后进程发生了变更
+
+	EventWatchdog EventType = "watchdog" // 进程的内存或 CPU 占用持续超过看门狗阈值
+	EventWatch    EventType = "watch"    // spm start --watch 监控的文件发生变化，进程被重启
+)
+
+// eventsTopic 进程事件使用的主题，日志使用进程全名作为主题
+const eventsTopic = "events"
+
+// subscriberBuffer 每个订阅者的缓冲区大小，缓冲区满时丢弃新消息，避免慢速订阅者阻塞进程
+const subscriberBuffer = 256
+
+// Event 进程生命周期事件
+type Event struct {
+	Time     time.Time    `json:"time"`
+	Type     EventType    `json:"type"`
+	Process  string       `json:"process"`
+	Pid      int          `json:"pid"`
+	Status   ProcessState `json:"status"`
+	ExitCode int          `json:"exit_code,omitempty"`
+	Message  string       `json:"message,omitempty"`
+}
+
+// LogLine 进程输出的一行日志
+type LogLine struct {
+	Time    time.Time `json:"time,omitzero"` // 读取的历史日志没有时间
+	Process string    `json:"process"`
+	Stream  string    `json:"stream"` // stdout 或 stderr
+	Line    string    `json:"line"`
+}
+
+var (
+	eventHub = pubsub.New[string, *Event](subscriberBuffer)
+	logHub   = pubsub.New[string, *LogLine](subscriberBuffer)
+)
+
+// publishEvent 发布进程事件，没有订阅者或订阅者缓冲区已满时直接丢弃
+func publishEvent(p *Process, typ EventType, exitCode int, message string) {
+	eventHub.TryPub(&Event{
+		Time:     time.Now(),
+		Type:     typ,
+		Process:  p.FullName,
+		Pid:      p.Pid,
+		Status:   p.State,
+		ExitCode: exitCode,
+		Message:  message,
+	}, eventsTopic)
+}
+
+// publishLog 发布进程输出的一行日志
+func publishLog(fullName, stream, line string) {
+	logHub.TryPub(&LogLine{
+		Time:    time.Now(),
+		Process: fullName,
+		Stream:  stream,
+		Line:    line,
+	}, fullName)
+}
+
+// SubscribeEvents 订阅所有进程的事件
+//
+// 返回：
+//
+//	<-chan *Event: 事件通道
+//	func(): 取消订阅，调用后通道会被关闭
+//
+// 发布使用非阻塞方式，订阅者处理不及时时会丢失事件
+func SubscribeEvents() (<-chan *Event, func()) {
+	ch := eventHub.Sub(eventsTopic)
+	return ch, func() {
+		eventHub.Unsub(ch)
+	}
+}
+
+// SubscribeLogs 订阅指定进程的输出日志
+//
+// 参数：
+//
+//	fullNames: 完整进程名列表（格式：appName::processName）
+//
+// 返回：
+//
+//	<-chan *LogLine: 日志通道
+//	func(): 取消订阅，调用后通道会被关闭
+func SubscribeLogs(fullNames ...string) (<-chan *LogLine, func()) {
+	ch := logHub.Sub(fullNames...)
+	return ch, func() {
+		logHub.Unsub(ch)
+	}
+}
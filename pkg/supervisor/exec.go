@@ -0,0 +1,115 @@
+// Package supervisor 提供在项目环境中运行一次性命令的功能
+package supervisor
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"spm/pkg/config"
+)
+
+// ExecCommand 构建在项目环境中运行一次性命令的 exec.Cmd，由调用方连接终端并等待退出
+//
+// 参数：
+//
+//	opts: LoadProcfileOption 加载的项目配置
+//	process: 进程名，为空时使用项目级的环境变量和 workDir；
+//	         指定时使用该进程合并后的环境变量、root 以及 user、group、groups
+//	args: 要运行的命令和参数
+//
+// 返回：
+//
+//	*exec.Cmd: 未启动的命令，标准输入输出没有设置
+//	error: 进程不存在、.env 文件无法读取或无法切换到配置的用户
+//
+// 注意事项：
+//
+//	与受管进程一样，命令只能看到合并后的环境变量，不继承调用方的环境；
+//	为了在终端中正常交互，额外保留调用方的 TERM。
+//	合并后的环境变量设置了 PATH 时按它查找命令，否则使用调用方的 PATH
+func ExecCommand(opts *ProcfileOption, process string, args []string) (*exec.Cmd, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("command is empty")
+	}
+
+	var env map[string]string
+	var dir string
+	var procOpt *ProcessOption
+
+	if process == "" {
+		envFile, err := LoadEnvFiles(opts.WorkDir, opts.EnvFile)
+		if err != nil {
+			return nil, &config.ConfigError{Path: opts.WorkDir, Op: "envFile", Err: err}
+		}
+		env = Merge(envFile, opts.Env)
+		dir = opts.WorkDir
+	} else {
+		var ok bool
+		if procOpt, ok = opts.Processes[process]; !ok {
+			names := slices.Sorted(maps.Keys(opts.Processes))
+			return nil, fmt.Errorf("process %q is not in the Procfile, available: %s", process, strings.Join(names, " "))
+		}
+		env = procOpt.Env
+		dir = procOpt.Root
+	}
+
+	exe, err := lookPathEnv(args[0], dir, env["PATH"])
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(exe, args[1:]...)
+	cmd.Args[0] = args[0]
+	cmd.Dir = dir
+	cmd.Env = make([]string, 0, len(env)+1)
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	if term, ok := os.LookupEnv("TERM"); ok && env["TERM"] == "" {
+		cmd.Env = append(cmd.Env, "TERM="+term)
+	}
+
+	if procOpt != nil {
+		attr, err := credentialAttr(procOpt)
+		if err != nil {
+			return nil, err
+		}
+		cmd.SysProcAttr = attr
+	}
+
+	return cmd, nil
+}
+
+// lookPathEnv 按 pathEnv 查找可执行文件，pathEnv 为空时使用当前进程的 PATH
+//
+// 含有 / 的路径不查找 PATH，相对路径基于 dir；PATH 中的相对目录同样基于 dir
+func lookPathEnv(file, dir, pathEnv string) (string, error) {
+	if strings.Contains(file, "/") {
+		return resolveExe(file, dir), nil
+	}
+
+	if pathEnv == "" {
+		return exec.LookPath(file)
+	}
+
+	for _, p := range filepath.SplitList(pathEnv) {
+		if p == "" {
+			p = "."
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+
+		path := filepath.Join(p, file)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return path, nil
+		}
+	}
+
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
@@ -0,0 +1,447 @@
+// Package supervisor 提供开发时按文件变化自动重启进程的功能
+package supervisor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	defaultWatchDebounce = 500 * time.Millisecond // 最后一次变化之后等待的时间，Procfile.options 中没有设置 debounce 时使用
+	watchSyncInterval    = time.Second            // 检查需要监控的进程和目录的间隔
+)
+
+// defaultWatchIgnore 总是忽略的文件和目录：版本控制、依赖和缓存目录，编辑器的临时文件和日志
+var defaultWatchIgnore = []string{
+	".git", ".hg", ".svn", "node_modules", "__pycache__", ".venv", ".idea", ".vscode",
+	"*.swp", "*.swx", "*~", ".#*", "#*#", "*.log", "*.pid",
+}
+
+// WatchOption 进程的文件监控配置，对应 Procfile.options 中进程的 watch
+//
+// 只在 spm start --watch 启动的进程上生效，Globs 和 Ignore 中的模式相对于进程的 Root：
+// 不含 "/" 的模式匹配任意目录下的文件名，含 "/" 的模式匹配完整的相对路径，"**" 匹配任意层目录
+type WatchOption struct {
+	Globs    []string // 需要监控的文件，例如 "**/*.py"、"*.go"，为空时监控所有文件
+	Ignore   []string // 忽略的文件和目录，例如 "tmp"、"static/**"
+	Debounce string   // 最后一次变化之后等待多久再重启，例如 300ms，默认 500ms
+}
+
+// watchSetting 解析后的文件监控配置
+type watchSetting struct {
+	globs    []string
+	ignore   []string
+	debounce time.Duration
+}
+
+// defaultWatchSetting 没有配置 watch 的进程使用 spm start --watch 时的监控配置
+var defaultWatchSetting = &watchSetting{debounce: defaultWatchDebounce}
+
+// String 格式化为 "globs=**/*.py ignore=tmp debounce=500ms" 的形式，用于 reload 时比较
+func (w *watchSetting) String() string {
+	if w == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("globs=%s ignore=%s debounce=%s",
+		strings.Join(w.globs, ","), strings.Join(w.ignore, ","), w.debounce)
+}
+
+// parseWatch 解析进程的文件监控配置
+//
+// 返回：
+//
+//	*watchSetting: 解析后的配置，opt 为 nil 时返回 nil
+//	error: 模式或等待时间无效
+func parseWatch(opt *WatchOption) (*watchSetting, error) {
+	if opt == nil {
+		return nil, nil
+	}
+
+	w := &watchSetting{debounce: defaultWatchDebounce}
+
+	for _, field := range []struct {
+		name     string
+		patterns []string
+		dst      *[]string
+	}{{"globs", opt.Globs, &w.globs}, {"ignore", opt.Ignore, &w.ignore}} {
+		for _, pattern := range field.patterns {
+			pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+			if pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern %q", field.name, pattern)
+			}
+			*field.dst = append(*field.dst, pattern)
+		}
+	}
+
+	if v := strings.TrimSpace(opt.Debounce); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("debounce: %w", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("debounce: %s must be positive", d)
+		}
+		w.debounce = d
+	}
+
+	return w, nil
+}
+
+// matches 判断相对路径是否需要监控
+func (w *watchSetting) matches(rel string) bool {
+	if len(w.globs) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(w.globs, func(pattern string) bool {
+		return matchGlob(pattern, rel)
+	})
+}
+
+// ignored 判断相对路径本身或它所在的任意一级目录是否被忽略
+func (w *watchSetting) ignored(rel string) bool {
+	segments := strings.Split(rel, "/")
+
+	for i := range segments {
+		prefix := strings.Join(segments[:i+1], "/")
+		for _, patterns := range [][]string{defaultWatchIgnore, w.ignore} {
+			for _, pattern := range patterns {
+				if matchGlob(pattern, prefix) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// matchGlob 用 pattern 匹配以 "/" 分隔的相对路径
+//
+// 不含 "/" 的模式只匹配最后一级名称，含 "/" 的模式逐级匹配，"**" 匹配零到多级目录
+func matchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
+
+// setWatch 开启或关闭 spm start --watch 为进程开启的文件监控
+func (p *Process) setWatch(on bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.watching = on
+}
+
+// watchTarget 一个正在监控文件的进程
+type watchTarget struct {
+	name     string
+	root     string
+	setting  *watchSetting
+	excludes []string // 进程的日志和 PID 目录，写入时不能触发重启
+}
+
+// fileWatchTarget 返回进程的文件监控目标，没有开启监控时返回 nil；定时任务不监控文件
+func (p *Process) fileWatchTarget() *watchTarget {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.watching || p.job != nil || p.Options == nil {
+		return nil
+	}
+
+	root, err := filepath.Abs(p.Options.Root)
+	if err != nil {
+		return nil
+	}
+
+	t := &watchTarget{name: p.FullName, root: root, setting: p.Options.watch}
+	if t.setting == nil {
+		t.setting = defaultWatchSetting
+	}
+
+	if outPath, _, err := p.logPaths(); err == nil {
+		t.excludes = append(t.excludes, filepath.Dir(outPath))
+	}
+	if p.pidPath != "" {
+		t.excludes = append(t.excludes, filepath.Dir(p.pidPath))
+	}
+	for i, dir := range t.excludes {
+		if abs, err := filepath.Abs(dir); err == nil {
+			t.excludes[i] = abs
+		}
+	}
+
+	return t
+}
+
+// key 用于判断监控目标是否发生变化
+func (t *watchTarget) key() string {
+	return fmt.Sprintf("%s %s %s %s", t.name, t.root, t.setting, strings.Join(t.excludes, ","))
+}
+
+// relPath 返回文件相对于进程 Root 的路径，文件不在 Root 下或在日志、PID 目录中时返回 false
+func (t *watchTarget) relPath(name string) (string, bool) {
+	for _, dir := range t.excludes {
+		if name == dir || strings.HasPrefix(name, dir+string(filepath.Separator)) {
+			return "", false
+		}
+	}
+
+	rel, err := filepath.Rel(t.root, name)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(rel), true
+}
+
+// fileWatch 守护进程中所有进程共用的文件监控，只在 runFileWatch 的 goroutine 中访问
+type fileWatch struct {
+	sv      *Supervisor
+	w       *fsnotify.Watcher
+	targets []*watchTarget
+	keys    []string
+
+	timers  map[string]*watchTimer // 每个进程等待中的重启
+	changed map[string]string      // 每个进程最近一次变化的文件
+	fired   chan watchTimer
+	gen     uint64 // 每次安排重启时递增，用于识别过期的 fired
+}
+
+// watchTimer 等待中的重启，到期后把自身的副本发送到 fired
+type watchTimer struct {
+	name  string
+	gen   uint64
+	timer *time.Timer
+}
+
+// runFileWatch 监控 spm start --watch 启动的进程的 Root，匹配的文件变化后等待 debounce 时间再重启进程
+//
+// 注意事项：
+//
+//	在守护进程中以 goroutine 运行，收到 stop 时退出；没有需要监控的进程时不占用 inotify 实例
+func (sv *Supervisor) runFileWatch(stop <-chan struct{}) {
+	fw := &fileWatch{
+		sv:      sv,
+		timers:  make(map[string]*watchTimer),
+		changed: make(map[string]string),
+		fired:   make(chan watchTimer, 16),
+	}
+	defer fw.close()
+
+	ticker := time.NewTicker(watchSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		var events chan fsnotify.Event
+		var errs chan error
+		if fw.w != nil {
+			events, errs = fw.w.Events, fw.w.Errors
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			fw.sync()
+		case ev := <-events:
+			fw.handle(ev)
+		case err := <-errs:
+			sv.logger.Warnf("File watch: %v", err)
+		case t := <-fw.fired:
+			fw.restart(t)
+		}
+	}
+}
+
+// sync 根据进程的监控状态更新监控的目录，监控目标变化时重新添加所有目录
+func (fw *fileWatch) sync() {
+	targets := make([]*watchTarget, 0)
+	keys := make([]string, 0)
+	for _, p := range fw.sv.procTable.Iter() {
+		if t := p.fileWatchTarget(); t != nil {
+			targets = append(targets, t)
+			keys = append(keys, t.key())
+		}
+	}
+	slices.Sort(keys)
+
+	if slices.Equal(keys, fw.keys) {
+		return
+	}
+
+	fw.close()
+	fw.targets, fw.keys = targets, keys
+	if len(targets) == 0 {
+		return
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		fw.sv.logger.Errorf("Cannot watch files: %v", err)
+		return
+	}
+	fw.w = w
+
+	roots := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if !slices.Contains(roots, t.root) {
+			roots = append(roots, t.root)
+		}
+	}
+	for _, root := range roots {
+		fw.addTree(root)
+	}
+}
+
+// close 关闭 inotify 实例并取消等待中的重启
+func (fw *fileWatch) close() {
+	if fw.w != nil {
+		_ = fw.w.Close()
+		fw.w = nil
+	}
+
+	for name, t := range fw.timers {
+		t.timer.Stop()
+		delete(fw.timers, name)
+	}
+	clear(fw.changed)
+}
+
+// addTree 监控目录及其下所有没有被忽略的子目录，inotify 不会自动监控子目录
+func (fw *fileWatch) addTree(dir string) {
+	_ = filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		if fw.skipDir(name) {
+			return filepath.SkipDir
+		}
+
+		if err := fw.w.Add(name); err != nil {
+			fw.sv.logger.Warnf("Cannot watch %s: %v", name, err)
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+}
+
+// skipDir 目录被所有相关进程忽略时不需要监控
+func (fw *fileWatch) skipDir(dir string) bool {
+	for _, t := range fw.targets {
+		if dir == t.root {
+			return false
+		}
+		if rel, ok := t.relPath(dir); ok && !t.setting.ignored(rel) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// handle 处理一个文件变化事件，新建的目录加入监控，匹配的变化安排重启
+func (fw *fileWatch) handle(ev fsnotify.Event) {
+	if ev.Op == fsnotify.Chmod {
+		return
+	}
+
+	if ev.Has(fsnotify.Create) {
+		if info, err := os.Lstat(ev.Name); err == nil && info.IsDir() {
+			fw.addTree(ev.Name)
+		}
+	}
+
+	for _, t := range fw.targets {
+		rel, ok := t.relPath(ev.Name)
+		if !ok || t.setting.ignored(rel) || !t.setting.matches(rel) {
+			continue
+		}
+
+		fw.changed[t.name] = rel
+		fw.schedule(t.name, t.setting.debounce)
+	}
+}
+
+// schedule 在 debounce 时间之后重启进程，已经在等待时重新计时
+//
+// 旧的定时器可能已经到期、fired 还没有被处理，不能 Reset 重用，
+// 每次都创建新的定时器并递增 gen，restart 据此忽略过期的 fired
+func (fw *fileWatch) schedule(name string, debounce time.Duration) {
+	if old, ok := fw.timers[name]; ok {
+		old.timer.Stop()
+	}
+
+	fw.gen++
+	t := &watchTimer{name: name, gen: fw.gen}
+	t.timer = time.AfterFunc(debounce, func() {
+		fw.fired <- watchTimer{name: t.name, gen: t.gen}
+	})
+	fw.timers[name] = t
+}
+
+// restart 在 debounce 时间内没有新的变化后重启进程，进程停止可能需要较长时间，不阻塞事件处理
+//
+// fired 不是进程当前等待中的定时器发出的（已经重新计时，或者监控目标变化后被 close 取消）时忽略
+func (fw *fileWatch) restart(fired watchTimer) {
+	name := fired.name
+	if t, ok := fw.timers[name]; !ok || t.gen != fired.gen {
+		return
+	}
+
+	rel := fw.changed[name]
+	delete(fw.changed, name)
+	delete(fw.timers, name)
+
+	p := fw.sv.procTable.Get(name)
+	if p == nil || p.fileWatchTarget() == nil {
+		return
+	}
+
+	message := fmt.Sprintf("%s changed", rel)
+	p.logger.Infof("Restarting process %s, %s", p.Name, message)
+	publishEvent(p, EventWatch, 0, message)
+
+	go fw.sv.Restart(name)
+}
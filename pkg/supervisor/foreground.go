@@ -0,0 +1,88 @@
+// Package supervisor 提供前台模式下带进程名前缀的彩色输出功能
+package supervisor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// foregroundColors 前台模式下进程名使用的 ANSI 前景色，按进程名的哈希选择，每次运行保持一致
+var foregroundColors = []int{36, 33, 32, 35, 34, 96, 93, 92, 95, 94}
+
+// foregroundWriter 把进程的输出逐行加上时间、进程名前缀后写到终端，
+// 同一时刻只写一行，避免多个进程的输出交错在同一行中
+//
+// 输出格式：
+//
+//	15:04:05 web    | 标准输出
+//	15:04:05 worker ! 标准错误
+type foregroundWriter struct {
+	mu    sync.Mutex
+	width int // 已注册的最长进程名，用于对齐
+	once  sync.Once
+	color bool
+}
+
+var foreground = &foregroundWriter{}
+
+// register 记录进程名的长度，使所有进程的输出对齐
+func (w *foregroundWriter) register(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.width = max(w.width, len(name))
+}
+
+// writeLine 输出进程的一行日志，标准错误写到 os.Stderr 并以 "!" 分隔
+func (w *foregroundWriter) writeLine(name, logtype, line string) {
+	w.once.Do(func() {
+		w.color = os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	})
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var out io.Writer = os.Stdout
+	sep := "|"
+	if logtype == "STDERR" {
+		out = os.Stderr
+		sep = "!"
+	}
+
+	ts := time.Now().Format(time.TimeOnly)
+	label := fmt.Sprintf("%-*s", w.width, name)
+
+	if !w.color {
+		_, _ = fmt.Fprintf(out, "%s %s %s %s\n", ts, label, sep, line)
+		return
+	}
+
+	color := nameColor(name)
+	if logtype == "STDERR" {
+		// 标准错误的分隔符固定为红色，进程名仍使用进程的颜色
+		_, _ = fmt.Fprintf(out, "\033[2m%s\033[0m \033[%dm%s\033[0m \033[1;31m%s\033[0m %s\n", ts, color, label, sep, line)
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "\033[2m%s\033[0m \033[%dm%s %s\033[0m %s\n", ts, color, label, sep, line)
+}
+
+// nameColor 返回进程名对应的颜色
+func nameColor(name string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+
+	return foregroundColors[h.Sum32()%uint32(len(foregroundColors))]
+}
+
+// isTerminal 判断文件是否连接到终端
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS)
+	return err == nil
+}
@@ -0,0 +1,23 @@
+// Package supervisor 提供内置的网页控制台
+package supervisor
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// dashboardFiles 网页控制台的静态文件，页面只调用 /api/v1 下的接口
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardHandler 返回提供网页控制台静态文件的处理器
+func dashboardHandler() http.Handler {
+	root, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err)
+	}
+
+	return http.FileServerFS(root)
+}
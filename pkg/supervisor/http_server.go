@@ -0,0 +1,810 @@
+// Package supervisor 提供守护进程内置的 HTTP/JSON API
+package supervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"spm/pkg/config"
+	"spm/pkg/logger"
+	"spm/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// httpAPIPrefix 所有 API 路径的前缀，接口不兼容时递增版本号
+	httpAPIPrefix = "/api/v1"
+
+	// sseHeartbeat SSE 连接的心跳间隔，防止空闲连接被代理断开
+	sseHeartbeat = 15 * time.Second
+
+	// maxTailLines 日志接口 tail 参数的上限
+	maxTailLines = 1000
+
+	// maxTailBytes 读取历史日志时最多从文件末尾读取的字节数
+	maxTailBytes = 1 << 20
+)
+
+// httpActions HTTP 路径中的操作名与控制操作的对应关系
+var httpActions = map[string]ActionCtl{
+	"start":   ActionStart,
+	"stop":    ActionStop,
+	"restart": ActionRestart,
+	"status":  ActionStatus,
+}
+
+// projectInfo 项目信息，用于 HTTP 接口返回
+type projectInfo struct {
+	Name      string           `json:"name"`
+	WorkDir   string           `json:"work_dir"`
+	Procfile  string           `json:"procfile"`
+	Processes []*processDetail `json:"processes"`
+}
+
+// processDetail 在 ProcInfo 的基础上增加运行时长和资源占用，用于 HTTP 接口返回
+type processDetail struct {
+	*ProcInfo
+	Uptime float64    `json:"uptime_seconds,omitempty"`
+	Usage  *ProcUsage `json:"usage,omitempty"`
+}
+
+// newProcessDetail 生成进程详情，进程未运行时不包含运行时长和资源占用
+func newProcessDetail(p *Process, name string) *processDetail {
+	detail := &processDetail{ProcInfo: newProcInfo(p, name)}
+	detail.Detail = newProcDetail(p)
+
+	if usage := p.Usage(); usage != nil {
+		detail.Usage = usage
+		if !p.StartAt.IsZero() {
+			detail.Uptime = time.Since(p.StartAt).Seconds()
+		}
+	}
+
+	return detail
+}
+
+// httpServer 守护进程的 HTTP/JSON API 服务
+//
+// 接口列表（路径均以 /api/v1 开头）：
+//
+//	GET  /version                       守护进程版本和协议版本
+//	GET  /projects                      所有项目及其进程状态、运行时长和资源占用
+//	GET  /projects/{project}            单个项目
+//	POST /projects/{project}/{action}   对项目的所有进程执行 start/stop/restart/status
+//	POST /projects/{project}/reload     重新加载项目配置，?dry_run=1 只返回变更计划
+//	GET  /processes                     所有进程状态
+//	GET  /processes/{process}           单个进程状态，进程名格式为 appName::processName
+//	POST /processes/{process}/{action}  对单个进程执行 start/stop/restart/status
+//	POST /processes/{process}/signal    向进程发送信号，?signal=HUP 或 {"signal": "HUP"}
+//	GET  /processes/{process}/logs      以 SSE 推送进程输出，?tail=N&stream=stdout|stderr
+//	GET  /events                        以 SSE 推送进程事件，?project= 只推送指定项目
+//	GET  /metrics                       Prometheus 格式的监控指标（不带 /api/v1 前缀）
+//
+// 开启 http.dashboard 时，其他路径提供内置的网页控制台
+//
+// 控制操作与控制套接字共用 SpmSession 的处理逻辑，响应体与 ResponseMsg 一致，
+// HTTP 状态码与 ResponseMsg.Code 一致
+type httpServer struct {
+	sv      *Supervisor
+	srv     *http.Server
+	acl     *accessControl
+	tokens  *remoteAuth // 监听 TCP 端口时检查的令牌，与远程控制共用 remote.tokens
+	network string      // 监听的网络类型，unix 或 tcp
+	logger  *zap.SugaredLogger
+}
+
+// StartHTTPServer 按 spm.yml 的 http 配置启动 HTTP/JSON API
+//
+// 返回：
+//
+//	*httpServer: 服务实例，未启用或启动失败时返回 nil
+//
+// 注意事项：
+//
+//	启动失败只记录错误日志，不影响守护进程和控制套接字
+func StartHTTPServer(sv *Supervisor) *httpServer {
+	cfg := config.GetConfig().HTTP
+	if !cfg.Enabled {
+		return nil
+	}
+
+	s := &httpServer{
+		sv:     sv,
+		logger: logger.Logging("spm-http"),
+	}
+
+	network, address, err := parseListen(cfg.Listen)
+	if err != nil {
+		s.logger.Error(err)
+		return nil
+	}
+	s.network = network
+
+	if network == "unix" {
+		removeStaleSocket(address)
+	}
+
+	access := config.GetConfig().Access
+	if network == "tcp" && len(access.Rules) > 0 {
+		// TCP 连接无法获取对端凭据，本机所有用户都可以连接，与访问规则的限制矛盾
+		s.logger.Errorf("http.listen: %s is a TCP address, which cannot enforce access.rules, use a unix socket", cfg.Listen)
+		return nil
+	}
+
+	if network == "tcp" {
+		s.tokens, err = newRemoteAuth(config.GetConfig().Remote, s.logger)
+		if err != nil {
+			s.logger.Error(err)
+			return nil
+		}
+	}
+
+	var ln net.Listener
+	if network == "unix" {
+		ln, err = listenUnix(address, access)
+	} else {
+		ln, err = net.Listen(network, address)
+	}
+	if err != nil {
+		s.logger.Error(err)
+		return nil
+	}
+	s.acl = newAccessControl(access, s.logger)
+
+	s.srv = &http.Server{
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnContext:       withPeerCred,
+	}
+
+	go func() {
+		err := s.srv.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error(err)
+		}
+	}()
+
+	s.logger.Infof("HTTP API listening on %s:%s", network, address)
+
+	return s
+}
+
+// removeStaleSocket 删除上次异常退出时遗留的套接字文件，否则监听会失败
+//
+// 只删除连接不上的套接字文件：能连接上说明有其他守护进程正在使用，由 listenUnix 返回错误
+func removeStaleSocket(path string) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return
+	}
+
+	_ = os.Remove(path)
+}
+
+// Close 关闭 HTTP 服务，正在推送的 SSE 连接会被立即断开
+func (s *httpServer) Close() {
+	if s == nil {
+		return
+	}
+
+	if err := s.srv.Close(); err != nil {
+		s.logger.Error(err)
+	}
+}
+
+// parseListen 解析 http.listen 配置，TCP 地址只允许回环地址
+func parseListen(listen string) (string, string, error) {
+	if path, ok := strings.CutPrefix(listen, "unix:"); ok {
+		if path == "" {
+			return "", "", fmt.Errorf("http.listen: empty unix socket path")
+		}
+		return "unix", path, nil
+	}
+
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", "", fmt.Errorf("http.listen: %w", err)
+	}
+
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return "", "", fmt.Errorf("http.listen: %s is not a loopback address", listen)
+		}
+	}
+
+	return "tcp", listen, nil
+}
+
+func (s *httpServer) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	// 只读接口按 status 操作检查权限
+	mux.HandleFunc("GET "+httpAPIPrefix+"/version", s.guard("status", s.handleVersion))
+	mux.HandleFunc("GET "+httpAPIPrefix+"/projects", s.guard("status", s.handleProjects))
+	mux.HandleFunc("GET "+httpAPIPrefix+"/projects/{project}", s.guard("status", s.handleProject))
+	mux.HandleFunc("POST "+httpAPIPrefix+"/projects/{project}/reload", s.instrument("reload", s.handleReload))
+	mux.HandleFunc("POST "+httpAPIPrefix+"/projects/{project}/{action}", s.instrument("", s.handleProjectAction))
+	mux.HandleFunc("GET "+httpAPIPrefix+"/processes", s.guard("status", s.handleProcesses))
+	mux.HandleFunc("GET "+httpAPIPrefix+"/processes/{process}", s.instrument("status", s.handleProcess))
+	mux.HandleFunc("POST "+httpAPIPrefix+"/processes/{process}/signal", s.instrument("signal", s.handleSignal))
+	mux.HandleFunc("POST "+httpAPIPrefix+"/processes/{process}/{action}", s.instrument("", s.handleProcessAction))
+	mux.HandleFunc("GET "+httpAPIPrefix+"/processes/{process}/logs", s.guard("status", s.handleLogs))
+	mux.HandleFunc("GET "+httpAPIPrefix+"/events", s.guard("status", s.handleEvents))
+	mux.HandleFunc("GET /metrics", s.guard("status", s.handleMetrics))
+
+	if config.GetConfig().HTTP.Dashboard {
+		mux.HandleFunc("GET /", s.guard("status", dashboardHandler().ServeHTTP))
+	}
+
+	return s.logRequest(s.checkOrigin(mux))
+}
+
+// checkOrigin 拒绝来自其他站点的浏览器请求
+//
+// 接口只监听本机地址，但浏览器中任意网页都可以向 127.0.0.1 发送请求：
+//   - 监听 TCP 端口时 Host 必须是回环地址或 localhost，防止 DNS 重绑定的网页把自己的域名解析到本机后读取接口
+//   - 带有 Origin 头且与 Host 不一致的请求视为跨站请求
+//
+// 两项检查作用于所有请求方法，命令行工具不发送 Origin 头，不受影响
+func (s *httpServer) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.network == "tcp" && !isLoopbackHost(r.Host) {
+			s.logger.Warnf("Rejected %s %s with host %q", r.Method, r.URL.Path, r.Host)
+			writeError(w, http.StatusForbidden, "Invalid host")
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || u.Host != r.Host {
+				s.logger.Warnf("Rejected cross-origin %s %s from %s", r.Method, r.URL.Path, origin)
+				writeError(w, http.StatusForbidden, "Cross-origin request rejected")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost 判断 Host 头是否为 localhost 或回环 IP，可以带端口
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// logRequest 记录每个请求的方法、路径和耗时
+func (s *httpServer) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		s.logger.Debugf("%s %s (%s)", r.Method, r.URL.Path, time.Since(start))
+	})
+}
+
+// statusRecorder 记录处理器写入的状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// routeAction 返回请求对应的操作名称，action 为空时使用路径中的 {action}
+func routeAction(action string, r *http.Request) string {
+	if action != "" {
+		return action
+	}
+
+	action = r.PathValue("action")
+	if _, ok := httpActions[action]; !ok {
+		return "unknown"
+	}
+
+	return action
+}
+
+// guard 按 spm.yml 的 access 规则检查对端是否可以执行操作
+//
+// 只有监听 Unix 套接字时才能获取对端凭据；监听本机 TCP 端口时本机的所有用户都可以连接，
+// 按 checkToken 检查 Authorization 头中的令牌
+func (s *httpServer) guard(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.network == "tcp" {
+			if err := s.checkToken(r, routeAction(action, r)); err != nil {
+				s.logger.Warnf("%s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				if errors.Is(err, ErrUnauthorized) {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="spm"`)
+				}
+				writeError(w, errorCode(err), err.Error())
+				return
+			}
+
+			next(w, r)
+			return
+		}
+
+		cred := peerCredFrom(r.Context())
+		if err := s.acl.check(cred, routeAction(action, r)); err != nil {
+			pid := int32(-1)
+			if cred != nil {
+				pid = cred.pid
+			}
+			s.logger.Warnf("%s %s from pid %d: %v", r.Method, r.URL.Path, pid, err)
+			writeError(w, errorCode(err), err.Error())
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// checkToken 检查 TCP 请求的令牌
+//
+// 没有令牌的请求只能执行 status（查看状态、日志、事件和监控指标），
+// 其他操作需要在 Authorization: Bearer 中提交 remote.tokens 中的令牌，并按令牌允许的操作检查。
+// 没有配置令牌时 TCP 端口是只读的
+func (s *httpServer) checkToken(r *http.Request, action string) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		if action == "status" {
+			return nil
+		}
+		return ErrUnauthorized
+	}
+
+	if len(s.tokens.tokens) == 0 {
+		return ErrUnauthorized
+	}
+
+	return s.tokens.check(token, action)
+}
+
+// instrument 检查权限，并记录控制请求的数量和耗时
+func (s *httpServer) instrument(action string, next http.HandlerFunc) http.HandlerFunc {
+	next = s.guard(action, next)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+
+		next(rec, r)
+
+		observeRequest("http", routeAction(action, r), rec.code, time.Since(start))
+	}
+}
+
+// session 创建执行控制操作的会话，HTTP 请求与控制套接字共用同一套处理逻辑
+func (s *httpServer) session() *SpmSession {
+	return &SpmSession{
+		sv:     s.sv,
+		logger: s.logger,
+	}
+}
+
+func (s *httpServer) handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"version":    utils.BinaryVersion,
+		"protocol":   ProtocolVersion,
+		"pid":        s.sv.Pid,
+		"started_at": s.sv.StartedAt,
+	})
+}
+
+func (s *httpServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	if err := s.sv.WriteMetrics(w); err != nil {
+		s.logger.Error(err)
+	}
+}
+
+func (s *httpServer) handleProjects(w http.ResponseWriter, r *http.Request) {
+	projects := s.sv.projectTable.Iter()
+
+	names := make([]string, 0, len(projects))
+	for name := range projects {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	infos := make([]*projectInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, s.projectInfo(projects[name]))
+	}
+
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *httpServer) handleProject(w http.ResponseWriter, r *http.Request) {
+	proj := s.sv.projectTable.Get(r.PathValue("project"))
+	if proj == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Project %s not found", r.PathValue("project")))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.projectInfo(proj))
+}
+
+// projectInfo 汇总项目中所有进程的状态，进程按名称排序
+func (s *httpServer) projectInfo(proj *Project) *projectInfo {
+	names := proj.GetProcNames()
+	slices.Sort(names)
+
+	procs := make([]*processDetail, 0, len(names))
+	for _, name := range names {
+		fullName := fmt.Sprintf("%s::%s", proj.Name, name)
+		procs = append(procs, newProcessDetail(s.sv.Status(fullName), fullName))
+	}
+
+	return &projectInfo{
+		Name:      proj.Name,
+		WorkDir:   proj.WorkDir,
+		Procfile:  proj.Procfile,
+		Processes: procs,
+	}
+}
+
+func (s *httpServer) handleProjectAction(w http.ResponseWriter, r *http.Request) {
+	action, ok := httpActions[r.PathValue("action")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Unknown action %s", r.PathValue("action")))
+		return
+	}
+
+	proj := s.sv.projectTable.Get(r.PathValue("project"))
+	if proj == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Project %s not found", r.PathValue("project")))
+		return
+	}
+
+	// 使用完整进程名，只操作该项目中的进程
+	names := proj.GetProcNames()
+	slices.Sort(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s::%s", proj.Name, name)
+	}
+
+	if len(names) == 0 {
+		writeJSON(w, http.StatusOK, &ResponseMsg{Code: http.StatusOK, Message: actionResponse[action]})
+		return
+	}
+
+	res := s.session().doAction(&ActionMsg{
+		Action:    action,
+		Processes: strings.Join(names, ";"),
+	})
+	writeJSON(w, res.Code, res)
+}
+
+func (s *httpServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	res := s.session().doReload(&ActionMsg{
+		Action:   ActionReload,
+		Projects: r.PathValue("project"),
+		DryRun:   dryRun,
+	})
+	writeJSON(w, res.Code, res)
+}
+
+func (s *httpServer) handleProcesses(w http.ResponseWriter, r *http.Request) {
+	procs := s.sv.StatusAll("*")
+
+	infos := make([]*processDetail, 0, len(procs))
+	for _, p := range procs {
+		infos = append(infos, newProcessDetail(p, ""))
+	}
+	slices.SortFunc(infos, func(a, b *processDetail) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *httpServer) handleProcess(w http.ResponseWriter, r *http.Request) {
+	s.processAction(w, ActionStatus, r.PathValue("process"))
+}
+
+func (s *httpServer) handleProcessAction(w http.ResponseWriter, r *http.Request) {
+	action, ok := httpActions[r.PathValue("action")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Unknown action %s", r.PathValue("action")))
+		return
+	}
+
+	s.processAction(w, action, r.PathValue("process"))
+}
+
+// processAction 对单个进程执行控制操作，进程名必须是完整进程名
+func (s *httpServer) processAction(w http.ResponseWriter, action ActionCtl, name string) {
+	if !strings.Contains(name, "::") {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Process name %s must be in the form appName::processName", name))
+		return
+	}
+
+	res := s.session().doAction(&ActionMsg{
+		Action:    action,
+		Processes: name,
+	})
+	writeJSON(w, res.Code, res)
+}
+
+func (s *httpServer) handleSignal(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("process")
+
+	sigName := r.URL.Query().Get("signal")
+	if sigName == "" {
+		var body struct {
+			Signal string `json:"signal"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sigName = body.Signal
+	}
+
+	if sigName == "" {
+		writeError(w, http.StatusBadRequest, "Missing signal")
+		return
+	}
+
+	sig, err := ParseSignal(sigName)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p, err := s.sv.Signal(name, sig)
+	if p.State == processNotfound {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Process not found: %s", name))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &ResponseMsg{
+		Code:      http.StatusOK,
+		Message:   fmt.Sprintf("Sent %v to %s", sig, name),
+		Processes: []*ProcInfo{newProcInfo(p, name)},
+	})
+}
+
+func (s *httpServer) handleLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("process")
+
+	p := s.sv.Status(name)
+	if p.State == processNotfound {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Process not found: %s", name))
+		return
+	}
+
+	query := r.URL.Query()
+
+	stream := query.Get("stream")
+	switch stream {
+	case "", "all":
+		stream = ""
+	case "stdout", "stderr":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown stream %s", stream))
+		return
+	}
+
+	tail := 0
+	if v := query.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid tail %s", v))
+			return
+		}
+		tail = min(n, maxTailLines)
+	}
+
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	// 先订阅再读取历史日志，避免两者之间的输出丢失
+	lines, unsub := SubscribeLogs(name)
+	defer unsub()
+
+	history, err := logHistory(p, stream, tail)
+	if err != nil {
+		s.logger.Error(err)
+	}
+	for _, line := range history {
+		if sse.Send("log", line) != nil {
+			return
+		}
+	}
+
+	streamSSE(sse, r, lines, func(line *LogLine) bool {
+		return stream == "" || line.Stream == stream
+	}, func(*LogLine) string {
+		return "log"
+	})
+}
+
+func (s *httpServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Query().Get("project")
+
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	events, unsub := SubscribeEvents()
+	defer unsub()
+
+	streamSSE(sse, r, events, func(e *Event) bool {
+		return project == "" || strings.HasPrefix(e.Process, project+"::")
+	}, func(e *Event) string {
+		return string(e.Type)
+	})
+}
+
+// sseWriter 按 Server-Sent Events 格式推送消息
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	if _, ok := w.(http.Flusher); !ok {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	sse := &sseWriter{w: w, rc: http.NewResponseController(w)}
+
+	return sse, sse.rc.Flush() == nil
+}
+
+// Send 推送一条消息，event 为空时不写 event 字段
+func (sse *sseWriter) Send(event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event)
+	}
+	fmt.Fprintf(&buf, "data: %s\n\n", data)
+
+	if _, err := sse.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	return sse.rc.Flush()
+}
+
+// heartbeat 发送 SSE 注释行，用于保持连接和检测客户端断开
+func (sse *sseWriter) heartbeat() error {
+	if _, err := io.WriteString(sse.w, ": ping\n\n"); err != nil {
+		return err
+	}
+
+	return sse.rc.Flush()
+}
+
+// streamSSE 持续推送通道中满足 filter 的消息，直到客户端断开或通道关闭
+//
+// event 返回每条消息的 SSE 事件名
+func streamSSE[T any](sse *sseWriter, r *http.Request, ch <-chan T, filter func(T) bool, event func(T) string) {
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if sse.heartbeat() != nil {
+				return
+			}
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !filter(msg) {
+				continue
+			}
+			if sse.Send(event(msg), msg) != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeJSON 以 JSON 格式写入响应
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+// writeError 以 ResponseMsg 的格式写入错误响应
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, &ResponseMsg{Code: code, Message: message})
+}
+
+// tailFile 读取文件最后 n 行，最多读取文件末尾 maxTailBytes 字节
+func tailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset := max(info.Size()-maxTailBytes, 0)
+	data := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	// 从文件中间开始读取时，第一行可能不完整
+	if offset > 0 && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil, nil
+	}
+
+	return lines[max(len(lines)-n, 0):], nil
+}
@@ -0,0 +1,279 @@
+// Package supervisor 提供按 schedule 定时运行进程的定时任务功能
+package supervisor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OverlapPolicy 定时任务到点时上一次运行还没有结束的处理方式
+type OverlapPolicy string
+
+const (
+	OverlapSkip  OverlapPolicy = "skip"  // 跳过本次运行（默认）
+	OverlapQueue OverlapPolicy = "queue" // 上一次结束后立即再运行一次，最多排队一次
+	OverlapAllow OverlapPolicy = "allow" // 同时运行多个实例
+)
+
+// parseOverlap 解析进程配置中的 overlap，为空时使用 skip
+func parseOverlap(s string) (OverlapPolicy, error) {
+	switch policy := OverlapPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return OverlapSkip, nil
+	case OverlapSkip, OverlapQueue, OverlapAllow:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid overlap %q, supported: skip queue allow", s)
+	}
+}
+
+// JobRun 定时任务最近一次运行的结果
+type JobRun struct {
+	StartAt  int64  `codec:"start_at" json:"start_at"`                   // 开始时间，Unix 毫秒
+	Duration int64  `codec:"duration_ms" json:"duration_ms"`             // 运行时长，毫秒
+	ExitCode int    `codec:"exit_code" json:"exit_code"`                 // 退出码，被信号终止时为 128+信号值
+	Message  string `codec:"message,omitempty" json:"message,omitempty"` // 非正常退出的原因
+}
+
+// JobInfo 定时任务的调度状态，作为 ProcInfo 的一部分返回
+type JobInfo struct {
+	Schedule string        `codec:"schedule" json:"schedule"`
+	Overlap  OverlapPolicy `codec:"overlap" json:"overlap"`
+	NextRun  int64         `codec:"next_run" json:"next_run"` // 下一次运行时间，Unix 毫秒，没有启用时为 0
+	Running  int           `codec:"running" json:"running"`   // 正在运行的实例数
+	Queued   bool          `codec:"queued" json:"queued"`     // 是否有排队等待的运行
+	LastRun  *JobRun       `codec:"last_run,omitempty" json:"last_run,omitempty"`
+}
+
+// jobState 定时任务的运行时状态，由进程和 allow 模式下的额外实例共享
+type jobState struct {
+	mu      sync.Mutex
+	armed   bool        // 是否已启用调度，start 时启用，stop 时停用
+	timer   *time.Timer // 下一次运行的定时器
+	next    time.Time
+	queued  bool
+	clones  map[*Process]struct{} // allow 模式下正在运行的额外实例
+	cloneID int
+	last    *JobRun
+}
+
+// IsJob 判断进程是否配置了 schedule
+func (p *Process) IsJob() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.job != nil
+}
+
+// armJob 启用定时任务的调度，已经启用时保持原来的下一次运行时间
+func (p *Process) armJob() bool {
+	p.mu.Lock()
+	j, schedule := p.job, p.Options.schedule
+	p.mu.Unlock()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.armed {
+		return true
+	}
+
+	j.armed = true
+	if !p.scheduleNext(j, schedule, time.Now()) {
+		j.armed = false
+		return false
+	}
+
+	p.logger.Infof("Job %s is scheduled (%s), next run at %s", p.Name, schedule, j.next.Format(time.RFC3339))
+
+	return true
+}
+
+// scheduleNext 按调度规则设置下一次运行的定时器，调用方需持有 j.mu
+func (p *Process) scheduleNext(j *jobState, schedule jobSchedule, now time.Time) bool {
+	j.next = schedule.Next(now)
+	if j.next.IsZero() {
+		p.logger.Warnf("Job %s has no next run for schedule %s", p.Name, schedule)
+		return false
+	}
+
+	j.timer = time.AfterFunc(time.Until(j.next), p.fireJob)
+
+	return true
+}
+
+// disarmJob 停用定时任务的调度并清除排队的运行，返回 allow 模式下正在运行的额外实例
+func (p *Process) disarmJob() []*Process {
+	p.mu.Lock()
+	j := p.job
+	p.mu.Unlock()
+
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.armed {
+		p.logger.Infof("Job %s is unscheduled", p.Name)
+	}
+
+	j.armed = false
+	j.queued = false
+	j.next = time.Time{}
+	if j.timer != nil {
+		j.timer.Stop()
+		j.timer = nil
+	}
+
+	clones := make([]*Process, 0, len(j.clones))
+	for c := range j.clones {
+		clones = append(clones, c)
+	}
+
+	return clones
+}
+
+// fireJob 定时器到点时运行任务，并安排下一次运行
+func (p *Process) fireJob() {
+	p.mu.Lock()
+	j, schedule, overlap := p.job, p.Options.schedule, p.Options.overlap
+	p.mu.Unlock()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// 停用后已经触发的定时器
+	if !j.armed || schedule == nil {
+		return
+	}
+
+	if !p.scheduleNext(j, schedule, time.Now()) {
+		j.armed = false
+	}
+
+	if !p.IsRunning() {
+		p.runJob(j)
+		return
+	}
+
+	switch overlap {
+	case OverlapQueue:
+		if !j.queued {
+			p.logger.Infof("Job %s is still running, queued the next run", p.Name)
+		}
+		j.queued = true
+	case OverlapAllow:
+		p.runClone(j)
+	default:
+		p.logger.Infof("Job %s is still running with PID %d, skipped this run", p.Name, p.Pid)
+	}
+}
+
+// runJob 运行一次任务，调用方需持有 j.mu
+func (p *Process) runJob(j *jobState) {
+	p.logger.Infof("Running job %s", p.Name)
+
+	if !p.start() {
+		j.last = &JobRun{StartAt: time.Now().UnixMilli(), ExitCode: -1, Message: "failed to start"}
+	}
+}
+
+// runClone 在 allow 模式下以额外实例运行一次任务，调用方需持有 j.mu
+//
+// 额外实例共享进程的日志文件和任务状态，不写 PID 文件，启用 cgroup 时使用单独的叶子 cgroup
+func (p *Process) runClone(j *jobState) {
+	p.mu.Lock()
+	opts := p.Options
+	p.mu.Unlock()
+
+	j.cloneID++
+
+	clone := NewProcess(p.FullName, opts)
+	clone.job = j
+	clone.cloneID = j.cloneID
+	clone.logger = p.logger
+
+	p.logger.Infof("Job %s is still running, starting instance #%d", p.Name, clone.cloneID)
+
+	if j.clones == nil {
+		j.clones = make(map[*Process]struct{})
+	}
+	j.clones[clone] = struct{}{}
+
+	if !clone.start() {
+		delete(j.clones, clone)
+		j.last = &JobRun{StartAt: time.Now().UnixMilli(), ExitCode: -1, Message: "failed to start"}
+	}
+}
+
+// onJobExit 记录任务一次运行的结果，有排队的运行时立即再运行一次
+func (p *Process) onJobExit(startAt, stopAt time.Time, exitCode int, message string) {
+	j := p.job
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.last = &JobRun{
+		StartAt:  startAt.UnixMilli(),
+		Duration: stopAt.Sub(startAt).Milliseconds(),
+		ExitCode: exitCode,
+		Message:  message,
+	}
+
+	p.logger.Infof("Job %s finished in %s with code=%d", p.Name, stopAt.Sub(startAt).Round(time.Millisecond), exitCode)
+
+	if p.cloneID > 0 {
+		delete(j.clones, p)
+		return
+	}
+
+	if j.queued && j.armed {
+		j.queued = false
+		p.runJob(j)
+	}
+}
+
+// jobInfo 返回定时任务的调度状态，不是定时任务时返回 nil
+func (p *Process) jobInfo() *JobInfo {
+	if p.Options == nil || p.job == nil {
+		return nil
+	}
+
+	j := p.job
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := &JobInfo{
+		Schedule: p.Options.schedule.String(),
+		Overlap:  p.Options.overlap,
+		Running:  len(j.clones),
+		Queued:   j.queued,
+		LastRun:  j.last,
+	}
+
+	if p.State == processRunning {
+		info.Running++
+	}
+
+	if !j.next.IsZero() {
+		info.NextRun = j.next.UnixMilli()
+	}
+
+	return info
+}
+
+// isArmed 判断定时任务是否已启用调度
+func (p *Process) isArmed() bool {
+	if p.job == nil {
+		return false
+	}
+
+	p.job.mu.Lock()
+	defer p.job.mu.Unlock()
+
+	return p.job.armed
+}
@@ -0,0 +1,299 @@
+// Package supervisor 提供 Prometheus 格式的监控指标
+package supervisor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"spm/pkg/utils"
+)
+
+// requestBuckets 控制请求耗时直方图的桶上限（秒），停止进程最长需要 stopTimeout
+var requestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// requestKey 控制请求计数的标签
+type requestKey struct {
+	transport string
+	action    string
+	code      int
+}
+
+// latencyKey 控制请求耗时的标签
+type latencyKey struct {
+	transport string
+	action    string
+}
+
+// histogram 累积直方图，counts[i] 为耗时不超过 requestBuckets[i] 的请求数
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// requestMetrics 记录控制请求的数量和耗时
+type requestMetrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	latency  map[latencyKey]*histogram
+}
+
+var controlMetrics = &requestMetrics{
+	requests: make(map[requestKey]uint64),
+	latency:  make(map[latencyKey]*histogram),
+}
+
+// observeRequest 记录一次控制请求
+//
+// 参数：
+//
+//	transport: 请求来源，socket、tcp 或 http
+//	action: 操作名称
+//	code: 响应状态码
+//	d: 处理耗时
+func observeRequest(transport, action string, code int, d time.Duration) {
+	m := controlMetrics
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{transport, action, code}]++
+
+	key := latencyKey{transport, action}
+	h, ok := m.latency[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(requestBuckets))}
+		m.latency[key] = h
+	}
+
+	seconds := d.Seconds()
+	for i, le := range requestBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// metricsWriter 按 Prometheus 文本格式输出指标
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+// header 输出指标的 HELP 和 TYPE 行，随后应连续输出该指标的所有样本
+func (mw *metricsWriter) header(name, typ, help string) {
+	_, _ = fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample 输出一个样本，labels 按 key, value 交替排列
+func (mw *metricsWriter) sample(name string, value float64, labels ...string) {
+	_, _ = mw.w.WriteString(name)
+
+	if len(labels) > 0 {
+		_ = mw.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				_ = mw.w.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(mw.w, `%s="%s"`, labels[i], escapeLabel(labels[i+1]))
+		}
+		_ = mw.w.WriteByte('}')
+	}
+
+	_, _ = fmt.Fprintf(mw.w, " %v\n", value)
+}
+
+// escapeLabel 按 Prometheus 文本格式转义标签值
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// WriteMetrics 输出守护进程和所有进程的监控指标
+//
+// 进程指标的标签：
+//
+//	project: 项目名称（Project.Name）
+//	process: 进程名称
+//	instance: 进程实例序号，与 Procodile 的 web.1 命名一致，目前每个进程只有实例 1
+//
+// CPU、内存和文件描述符从 /proc 读取，只统计主进程，进程未运行时不输出
+func (sv *Supervisor) WriteMetrics(w io.Writer) error {
+	mw := &metricsWriter{w: bufio.NewWriter(w)}
+
+	mw.header("spm_build_info", "gauge", "Version of the running spm daemon.")
+	mw.sample("spm_build_info", 1, "version", utils.BinaryVersion)
+
+	mw.header("spm_supervisor_start_time_seconds", "gauge", "Start time of the spm daemon since unix epoch in seconds.")
+	mw.sample("spm_supervisor_start_time_seconds", float64(sv.StartedAt.UnixNano())/1e9)
+
+	projects := sv.projectTable.Iter()
+	mw.header("spm_projects", "gauge", "Number of registered projects.")
+	mw.sample("spm_projects", float64(len(projects)))
+
+	projNames := make([]string, 0, len(projects))
+	for name := range projects {
+		projNames = append(projNames, name)
+	}
+	slices.Sort(projNames)
+
+	snaps := make([]*procSnapshot, 0)
+	for _, projName := range projNames {
+		procNames := projects[projName].GetProcNames()
+		slices.Sort(procNames)
+
+		for _, name := range procNames {
+			p := sv.procTable.Get(fmt.Sprintf("%s::%s", projName, name))
+			if p == nil {
+				continue
+			}
+			snaps = append(snaps, sv.snapshotProcess(projName, p))
+		}
+	}
+
+	writeProcessMetrics(mw, snaps)
+	writeRequestMetrics(mw)
+
+	return mw.w.Flush()
+}
+
+// procSnapshot 输出指标时单个进程的状态快照
+type procSnapshot struct {
+	labels   []string
+	up       bool
+	restarts int
+	exitCode int
+	hasExit  bool
+	startAt  time.Time
+	usage    *ProcUsage
+}
+
+// snapshotProcess 读取进程状态和资源占用
+//
+// 资源占用与 spm status -v 一致，见 ProcUsage：启用 cgroup 时 CPU 时间和内存按整个叶子 cgroup 统计
+func (sv *Supervisor) snapshotProcess(projName string, p *Process) *procSnapshot {
+	running := p.IsRunning()
+
+	p.mu.Lock()
+	snap := &procSnapshot{
+		labels:   []string{"project", projName, "process", p.Name, "instance", "1"},
+		up:       running,
+		restarts: max(p.starts-1, 0),
+		exitCode: p.exitCode,
+		hasExit:  p.hasExit,
+		startAt:  p.StartAt,
+	}
+	pid := p.Pid
+	p.mu.Unlock()
+
+	if running && pid > 0 {
+		snap.usage = p.Usage()
+	}
+
+	return snap
+}
+
+// writeProcessMetrics 按指标分组输出所有进程的指标，同一指标的样本必须连续输出
+func writeProcessMetrics(mw *metricsWriter, snaps []*procSnapshot) {
+	mw.header("spm_process_up", "gauge", "Whether the process is running (1) or not (0).")
+	for _, s := range snaps {
+		up := 0.0
+		if s.up {
+			up = 1
+		}
+		mw.sample("spm_process_up", up, s.labels...)
+	}
+
+	mw.header("spm_process_restarts_total", "counter", "Number of times the process was started again after its first start.")
+	for _, s := range snaps {
+		mw.sample("spm_process_restarts_total", float64(s.restarts), s.labels...)
+	}
+
+	mw.header("spm_process_last_exit_code", "gauge", "Exit code of the last exit, 128+signal when killed by a signal.")
+	for _, s := range snaps {
+		if s.hasExit {
+			mw.sample("spm_process_last_exit_code", float64(s.exitCode), s.labels...)
+		}
+	}
+
+	mw.header("spm_process_start_time_seconds", "gauge", "Start time of the process since unix epoch in seconds.")
+	for _, s := range snaps {
+		if s.up && !s.startAt.IsZero() {
+			mw.sample("spm_process_start_time_seconds", float64(s.startAt.UnixNano())/1e9, s.labels...)
+		}
+	}
+
+	mw.header("spm_process_cpu_seconds_total", "counter", "Total user and system CPU time spent by the process, or its whole cgroup when enabled, in seconds.")
+	for _, s := range snaps {
+		if s.usage != nil {
+			mw.sample("spm_process_cpu_seconds_total", s.usage.CPUSeconds, s.labels...)
+		}
+	}
+
+	mw.header("spm_process_resident_memory_bytes", "gauge", "Resident memory size of the process, or memory.current of its cgroup when enabled, in bytes.")
+	for _, s := range snaps {
+		if s.usage != nil {
+			mw.sample("spm_process_resident_memory_bytes", float64(s.usage.RSSBytes), s.labels...)
+		}
+	}
+
+	mw.header("spm_process_open_fds", "gauge", "Number of open file descriptors of the process.")
+	for _, s := range snaps {
+		if s.usage != nil && s.usage.FDs >= 0 {
+			mw.sample("spm_process_open_fds", float64(s.usage.FDs), s.labels...)
+		}
+	}
+}
+
+// writeRequestMetrics 输出控制请求的数量和耗时
+func writeRequestMetrics(mw *metricsWriter) {
+	m := controlMetrics
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		if c := strings.Compare(a.transport+a.action, b.transport+b.action); c != 0 {
+			return c
+		}
+		return a.code - b.code
+	})
+
+	mw.header("spm_control_requests_total", "counter", "Number of control requests handled, by transport, action and response code.")
+	for _, k := range keys {
+		mw.sample("spm_control_requests_total", float64(m.requests[k]),
+			"transport", k.transport, "action", k.action, "code", fmt.Sprint(k.code))
+	}
+
+	lkeys := make([]latencyKey, 0, len(m.latency))
+	for k := range m.latency {
+		lkeys = append(lkeys, k)
+	}
+	slices.SortFunc(lkeys, func(a, b latencyKey) int {
+		return strings.Compare(a.transport+a.action, b.transport+b.action)
+	})
+
+	mw.header("spm_control_request_duration_seconds", "histogram", "Time spent handling control requests in seconds.")
+	for _, k := range lkeys {
+		h := m.latency[k]
+		for i, le := range requestBuckets {
+			mw.sample("spm_control_request_duration_seconds_bucket", float64(h.counts[i]),
+				"transport", k.transport, "action", k.action, "le", fmt.Sprint(le))
+		}
+		mw.sample("spm_control_request_duration_seconds_bucket", float64(h.count),
+			"transport", k.transport, "action", k.action, "le", "+Inf")
+		mw.sample("spm_control_request_duration_seconds_sum", h.sum,
+			"transport", k.transport, "action", k.action)
+		mw.sample("spm_control_request_duration_seconds_count", float64(h.count),
+			"transport", k.transport, "action", k.action)
+	}
+}
@@ -4,6 +4,7 @@ package supervisor
 import (
 	"fmt"
 	"strings"
+	"syscall"
 )
 
 // Status 获取单个进程的状态
@@ -214,6 +215,13 @@ func (sv *Supervisor) Stop(name string) *Process {
 	appName := strings.Split(name, "::")[0]
 	proj := sv.projectTable.Get(appName)
 
+	// 定时任务在两次运行之间没有进程，也需要停用调度
+	if p.IsJob() && !p.IsRunning() {
+		p.Stop()
+		proj.SetState(p.Name, false)
+		return p
+	}
+
 	if p.State == processRunning && proj.GetState(p.Name) {
 		if p.Stop() {
 			proj.SetState(p.Name, false)
@@ -267,14 +275,8 @@ func (sv *Supervisor) StopAll(appName string) []*Process {
 			if proj.GetState(name) {
 				return sv.Stop(fullName)
 			}
-		}
-	} else {
-		pt := sv.procTable.Iter()
-
-		for name := range pt {
-			p := sv.Stop(name)
-			procs = append(procs, p)
-		}
+			return nil
+		})
 	}
 
 	// 对于所有项目，直接调用 Stop
@@ -324,3 +326,34 @@ func (sv *Supervisor) RestartAll(appName string) []*Process {
 	sv.StopAll(appName)
 	return sv.StartAll(appName)
 }
+
+// Signal 向单个进程发送信号
+//
+// 参数：
+//
+//	name: 完整进程名
+//	sig: 要发送的信号
+//
+// 返回：
+//
+//	*Process: 进程实例，不存在时返回 notFoundProc
+//	error: 进程未运行或发送信号失败时返回错误
+//
+// 注意事项：
+//
+//	只发送信号，不修改进程状态，进程因信号退出时由监控 goroutine 更新状态
+//
+// 示例：
+//
+//	proc, err := sv.Signal("myapp::web-server", syscall.SIGHUP)
+func (sv *Supervisor) Signal(name string, sig syscall.Signal) (*Process, error) {
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+
+	p := sv.procTable.Get(name)
+	if p == nil {
+		return notFoundProc, nil
+	}
+
+	return p, p.Signal(sig)
+}
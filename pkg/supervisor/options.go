@@ -3,26 +3,39 @@ package supervisor
 import (
 	"errors"
 	"fmt"
-	"log"
 	"maps"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 
 	"spm/pkg/config"
 
 	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
 )
 
 // procfileViperMutex 保护 Procfile 配置加载时的 viper 全局状态操作
 var procfileViperMutex sync.Mutex
 
+// ProcfileOption 项目级配置，对应 Procfile.options 文件
+//
+// 环境变量优先级（从低到高）：
+//  1. spm.yml 中的全局 env
+//  2. 项目级 envFile 文件（按列表顺序，后者覆盖前者）
+//  3. 项目级 env
+//  4. 进程级 envFile 文件
+//  5. 进程级 env
 type ProcfileOption struct {
 	AppName   string
 	WorkDir   string
 	Procfile  string
+	EnvFile   []string
 	Env       map[string]string
 	Processes map[string]*ProcessOption
+
+	CgroupOption `mapstructure:",squash"` // 作用于整个项目的 cgroup 资源限制
 }
 
 type ProcessOption struct {
@@ -31,11 +44,98 @@ type ProcessOption struct {
 	LogRoot    string
 	StopSignal string
 	NumProcs   int
+	EnvFile    []string
 	Env        map[string]string
+	User       string            // 运行进程的用户，用户名或 UID，为空时使用守护进程的用户
+	Group      string            // 进程的主组，组名或 GID，为空时使用 User 的主组
+	Groups     []string          // 进程的附加组，为空时使用 User 所属的组
+	Rlimits    map[string]string // 资源限制，例如 nofile: "1024:4096"、core: 0
+	Watchdog   *WatchdogOption   // 内存和 CPU 占用超过阈值时自动重启
+	Schedule   string            // 定时任务的 cron 表达式或间隔，设置后进程按计划运行
+	Overlap    string            // 定时任务到点时上一次还在运行的处理方式：skip、queue、allow
+	Tty        bool              // 在伪终端中运行，可以用 spm attach 连接到进程的终端
+	Watch      *WatchOption      // spm start --watch 时监控的文件，匹配的文件变化后重启进程
+
+	CgroupOption `mapstructure:",squash"` // 作用于进程叶子 cgroup 的资源限制
+	SchedOption  `mapstructure:",squash"` // nice、ionice、oomScoreAdj 和 cpuAffinity
 
-	cmd []string
+	cmd           []string
+	credential    *syscall.Credential // 由 User、Group、Groups 解析得到
+	rlimits       []rlimitSetting     // 由 Rlimits 解析得到
+	cgroup        map[string]string   // 由 CgroupOption 解析得到
+	projectCgroup map[string]string   // 项目级 CgroupOption 解析得到的限制
+	watchdog      *watchdogSetting    // 由 Watchdog 解析得到
+	watch         *watchSetting       // 由 Watch 解析得到
+	sched         *schedSetting       // 由 SchedOption 解析得到
+	schedule      jobSchedule         // 由 Schedule 解析得到
+	overlap       OverlapPolicy       // 由 Overlap 解析得到
+	adhoc         bool                // 由 spm run 注册，不在 Procfile 中
+	restart       RestartPolicy       // spm run 指定的重启策略
 }
 
+// restoreEnvKeys 从 Procfile.options 重新读取项目级和进程级的 env，保留变量名的大小写
+//
+// viper 会把 map 的键转成小写，PATH、http_proxy 这类变量名需要原样保留，
+// 否则 env 无法覆盖 envFile 中的同名变量。没有配置文件时不做任何处理
+func restoreEnvKeys(file string, procOpts *ProcfileOption) error {
+	if file == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+
+	if env := envFromNode(lookupKey(root, "env")); env != nil {
+		procOpts.Env = env
+	}
+
+	procs := lookupKey(root, "processes")
+	for name, opt := range procOpts.Processes {
+		if opt == nil {
+			continue
+		}
+		if env := envFromNode(lookupKey(lookupKey(procs, name), "env")); env != nil {
+			opt.Env = env
+		}
+	}
+
+	return nil
+}
+
+// envFromNode 把 YAML 映射节点转换为环境变量，不是映射时返回 nil
+func envFromNode(n *yaml.Node) map[string]string {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	env := make(map[string]string, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		val := n.Content[i+1]
+		if val.Kind == yaml.ScalarNode && val.Tag != "!!null" {
+			env[n.Content[i].Value] = val.Value
+		} else {
+			env[n.Content[i].Value] = ""
+		}
+	}
+
+	return env
+}
+
+// LoadProcfileOption 加载项目的 Procfile 和 Procfile.options
+//
+// 配置文件读取、解析失败或运行时目录不可用时返回 *config.ConfigError，
+// 守护进程据此向客户端返回错误而不是退出
 func LoadProcfileOption(cwd string, procfile string) (*ProcfileOption, error) {
 	procfileViperMutex.Lock()
 	defer procfileViperMutex.Unlock()
@@ -50,7 +150,7 @@ func LoadProcfileOption(cwd string, procfile string) (*ProcfileOption, error) {
 		viper.AddConfigPath("etc")
 		viper.AddConfigPath("../etc")
 	} else if err != nil {
-		log.Fatal(err)
+		return nil, &config.ConfigError{Path: optsFile, Op: "stat", Err: err}
 	} else {
 		viper.SetConfigFile(optsFile)
 	}
@@ -67,23 +167,29 @@ func LoadProcfileOption(cwd string, procfile string) (*ProcfileOption, error) {
 
 	err = viper.ReadInConfig()
 	if err != nil && !errors.As(err, &viper.ConfigFileNotFoundError{}) {
-		log.Fatalf("Error getting config file, %s", err)
-		return nil, err
+		return nil, &config.ConfigError{Path: viper.ConfigFileUsed(), Op: "read", Err: err}
 	}
 
 	err = viper.Unmarshal(&procOpts)
 	if err != nil {
-		fmt.Println("Unable to decode into struct, ", err)
-		return nil, err
+		return nil, &config.ConfigError{Path: viper.ConfigFileUsed(), Op: "parse", Err: err}
+	}
+
+	if err = restoreEnvKeys(viper.ConfigFileUsed(), procOpts); err != nil {
+		return nil, &config.ConfigError{Path: viper.ConfigFileUsed(), Op: "parse", Err: err}
 	}
 
 	procFileCfg, err := LoadProcfile(procOpts.Procfile)
 	if err != nil {
-		return nil, err
+		return nil, &config.ConfigError{Path: procOpts.Procfile, Op: "load", Err: err}
 	}
 
 	if !procFileCfg.IsValid() {
-		return nil, errors.New(`invalid Procfile format, process name must be consist of 'a-z A-Z 0-9 - _'`)
+		return nil, &config.ConfigError{
+			Path: procOpts.Procfile,
+			Op:   "validate",
+			Err:  errors.New(`invalid Procfile format, process name must be consist of 'a-z A-Z 0-9 - _'`),
+		}
 	}
 
 	if len(procOpts.Processes) > 0 {
@@ -100,14 +206,35 @@ func LoadProcfileOption(cwd string, procfile string) (*ProcfileOption, error) {
 		procOpts.WorkDir = cwd
 	}
 
+	// 项目级 .env 文件，相对路径基于 WorkDir
+	projEnvFile, err := LoadEnvFiles(procOpts.WorkDir, procOpts.EnvFile)
+	if err != nil {
+		return nil, &config.ConfigError{Path: procOpts.WorkDir, Op: "envFile", Err: err}
+	}
+
+	runtimeDir, err := config.GetRuntimeDir(cwd)
+	if err != nil {
+		return nil, err
+	}
+	projEnv := Merge(projEnvFile, procOpts.Env)
+
+	projCgroup, err := parseCgroupLimits(procOpts.CgroupOption)
+	if err != nil {
+		return nil, &config.ConfigError{Path: viper.ConfigFileUsed(), Op: "cgroup", Err: err}
+	}
+
 	for name, cmd := range *procFileCfg {
 		opt, ok := procOpts.Processes[name]
 		if !ok {
 			opt = &ProcessOption{}
+			procOpts.Processes[name] = opt
 		}
 
 		if opt.Root == "" {
 			opt.Root = cwd
+		} else if !filepath.IsAbs(opt.Root) {
+			// 与 spm check 一致，相对路径相对于项目目录，不依赖守护进程的当前目录
+			opt.Root = filepath.Join(cwd, opt.Root)
 		}
 		if opt.NumProcs <= 0 {
 			opt.NumProcs = 1
@@ -116,21 +243,91 @@ func LoadProcfileOption(cwd string, procfile string) (*ProcfileOption, error) {
 		}
 
 		if opt.PidRoot == "" {
-			opt.PidRoot = config.GetRuntimeDir(cwd)
+			opt.PidRoot = runtimeDir
 		}
 
 		if opt.LogRoot == "" {
-			opt.LogRoot = config.GetRuntimeDir(cwd)
+			opt.LogRoot = runtimeDir
 		}
 
 		if opt.StopSignal == "" {
 			opt.StopSignal = "TERM"
 		}
 
-		if opt.Env == nil {
-			opt.Env = maps.Clone(procOpts.Env)
-		} else {
-			opt.Env = Merge(procOpts.Env, opt.Env)
+		// 进程级 .env 文件，相对路径基于进程的 Root
+		procEnvFile, err := LoadEnvFiles(opt.Root, opt.EnvFile)
+		if err != nil {
+			return nil, &config.ConfigError{Path: opt.Root, Op: "envFile", Err: err}
+		}
+		opt.Env = Merge(projEnv, procEnvFile, opt.Env)
+
+		opt.credential, err = resolveCredential(opt)
+		if err != nil {
+			return nil, &config.ConfigError{
+				Path: viper.ConfigFileUsed(),
+				Op:   "user",
+				Err:  fmt.Errorf("process %s: %w", name, err),
+			}
+		}
+
+		opt.rlimits, err = resolveRlimits(opt.Rlimits)
+		if err != nil {
+			return nil, &config.ConfigError{
+				Path: viper.ConfigFileUsed(),
+				Op:   "rlimits",
+				Err:  fmt.Errorf("process %s: %w", name, err),
+			}
+		}
+
+		opt.cgroup, err = parseCgroupLimits(opt.CgroupOption)
+		if err != nil {
+			return nil, &config.ConfigError{
+				Path: viper.ConfigFileUsed(),
+				Op:   "cgroup",
+				Err:  fmt.Errorf("process %s: %w", name, err),
+			}
+		}
+		opt.projectCgroup = projCgroup
+
+		opt.sched, err = parseSched(opt.SchedOption)
+		if err != nil {
+			return nil, &config.ConfigError{
+				Path: viper.ConfigFileUsed(),
+				Op:   "sched",
+				Err:  fmt.Errorf("process %s: %w", name, err),
+			}
+		}
+
+		if opt.Schedule != "" {
+			opt.schedule, err = parseSchedule(opt.Schedule)
+			if err == nil {
+				opt.overlap, err = parseOverlap(opt.Overlap)
+			}
+			if err != nil {
+				return nil, &config.ConfigError{
+					Path: viper.ConfigFileUsed(),
+					Op:   "schedule",
+					Err:  fmt.Errorf("process %s: %w", name, err),
+				}
+			}
+		}
+
+		opt.watchdog, err = parseWatchdog(opt.Watchdog)
+		if err != nil {
+			return nil, &config.ConfigError{
+				Path: viper.ConfigFileUsed(),
+				Op:   "watchdog",
+				Err:  fmt.Errorf("process %s: %w", name, err),
+			}
+		}
+
+		opt.watch, err = parseWatch(opt.Watch)
+		if err != nil {
+			return nil, &config.ConfigError{
+				Path: viper.ConfigFileUsed(),
+				Op:   "watch",
+				Err:  fmt.Errorf("process %s: %w", name, err),
+			}
 		}
 
 		var args []string
@@ -0,0 +1,52 @@
+package supervisor
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreEnvKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Procfile.options")
+	content := `env:
+  Path: /opt/bin
+  http_proxy: http://proxy
+  PORT: 8080
+processes:
+  Web:
+    env:
+      NODE_ENV: production
+      Empty:
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// viper 解析后的结果：键已被转成小写
+	procOpts := &ProcfileOption{
+		Env: map[string]string{"path": "/opt/bin", "http_proxy": "http://proxy", "port": "8080"},
+		Processes: map[string]*ProcessOption{
+			"web":    {Env: map[string]string{"node_env": "production", "empty": ""}},
+			"worker": {},
+		},
+	}
+
+	if err := restoreEnvKeys(file, procOpts); err != nil {
+		t.Fatalf("restoreEnvKeys() error = %v", err)
+	}
+
+	wantProj := map[string]string{"Path": "/opt/bin", "http_proxy": "http://proxy", "PORT": "8080"}
+	if !maps.Equal(procOpts.Env, wantProj) {
+		t.Errorf("project env = %q, want %q", procOpts.Env, wantProj)
+	}
+
+	wantWeb := map[string]string{"NODE_ENV": "production", "Empty": ""}
+	if got := procOpts.Processes["web"].Env; !maps.Equal(got, wantWeb) {
+		t.Errorf("web env = %q, want %q", got, wantWeb)
+	}
+
+	if got := procOpts.Processes["worker"].Env; got != nil {
+		t.Errorf("worker env = %q, want nil", got)
+	}
+}
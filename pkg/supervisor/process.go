@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,6 +23,7 @@ import (
 
 	_ "github.com/k0kubun/pp/v3"
 	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
 )
 
 type ProcessState string
@@ -34,8 +37,13 @@ const (
 	processRunning  ProcessState = "Running"
 	processStandby  ProcessState = "Standby"
 	processFailed   ProcessState = "Failed"
+
+	processScheduled ProcessState = "Scheduled" // 定时任务已启用调度，等待下一次运行
 )
 
+// stopTimeout 停止进程时等待其退出的最长时间
+const stopTimeout = 10 * time.Second
+
 var sigTable = map[string]syscall.Signal{
 	"INT":   syscall.SIGINT,
 	"TERM":  syscall.SIGTERM,
@@ -44,6 +52,30 @@ var sigTable = map[string]syscall.Signal{
 	"ABORT": syscall.SIGABRT,
 }
 
+// ParseSignal 解析信号名称，支持 HUP、SIGHUP 和数字形式，不区分大小写
+func ParseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+
+	if num, err := strconv.Atoi(name); err == nil && num > 0 {
+		return syscall.Signal(num), nil
+	}
+
+	if sig, ok := sigTable[name]; ok {
+		return sig, nil
+	}
+
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, fmt.Errorf("unknown signal %q", name)
+	}
+
+	return sig, nil
+}
+
 var notFoundProc = &Process{
 	Pid:      -1,
 	FullName: "",
@@ -71,10 +103,48 @@ type Process struct {
 	logger  *zap.SugaredLogger
 	signal  syscall.Signal
 	sysproc *os.Process
+	exited  chan struct{}
 	pidPath string
+
+	stopping bool // Stop 已经要求当前实例退出，IsRunning 不会修改，用于区分进程是否自己退出
+	starts   int  // 成功启动的次数，重启次数为 starts-1
+	exitCode int  // 最近一次退出的退出码，被信号终止时为 128+信号值
+	hasExit  bool // 是否已经退出过，没有退出过时 exitCode 无意义
+
+	job     *jobState // 配置了 schedule 时的定时任务状态
+	cloneID int       // allow 模式下定时任务额外实例的编号，主进程为 0
+
+	restartTimer *time.Timer   // 临时进程等待中的自动重启
+	restartDelay time.Duration // 临时进程下一次自动重启前的等待时间
+
+	console  *console // 配置了 tty 时进程的伪终端
+	watching bool     // 由 spm start --watch 开启了文件监控，spm stop 时关闭
 }
 
 func NewProcess(fullName string, opts *ProcessOption) *Process {
+	name := strings.Split(fullName, "::")[1]
+
+	p := &Process{
+		Pid:      -1,
+		Name:     name,
+		FullName: fullName,
+		StartAt:  time.Time{},
+		StopAt:   time.Time{},
+		State:    processStandby,
+
+		logger: logger.Logging(fullName),
+	}
+	p.applyOptions(opts)
+
+	if config.ForegroundFlag {
+		foreground.register(name)
+	}
+
+	return p
+}
+
+// applyOptions 根据进程配置设置停止信号和环境变量，新配置在下次启动时生效
+func (p *Process) applyOptions(opts *ProcessOption) {
 	stopSignal, ok := sigTable[opts.StopSignal]
 	if !ok {
 		stopSignal = sigTable["TERM"]
@@ -85,49 +155,84 @@ func NewProcess(fullName string, opts *ProcessOption) *Process {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	name := strings.Split(fullName, "::")[1]
-
-	return &Process{
-		Pid:      -1,
-		Name:     name,
-		FullName: fullName,
-		Options:  opts,
-		StartAt:  time.Time{},
-		StopAt:   time.Time{},
-		State:    processStandby,
-		Env:      env,
+	p.Options = opts
+	p.Env = env
+	p.signal = stopSignal
 
-		signal: stopSignal,
-		logger: logger.Logging(fullName),
+	switch {
+	case opts.schedule == nil:
+		p.job = nil
+	case p.job == nil:
+		p.job = &jobState{}
 	}
 }
 
-func (p *Process) SetPidPath() {
+// UpdateOptions 替换进程配置，返回环境变量是否发生了变化
+func (p *Process) UpdateOptions(opts *ProcessOption) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.pidPath = fmt.Sprintf("%s/%s.pid", config.GetRuntimeDir(p.Options.Root), p.Name)
+	changed := !maps.Equal(p.Options.Env, opts.Env)
+	p.applyOptions(opts)
+
+	return changed
+}
+
+func (p *Process) SetPidPath() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
 	if p.Options.PidRoot != "" {
 		info, err := os.Stat(p.Options.PidRoot)
 		if err == nil && info.IsDir() {
 			p.pidPath = fmt.Sprintf("%s/%s.pid", p.Options.PidRoot, p.Name)
+			return
 		}
 	}
+
+	runtimeDir, err := config.GetRuntimeDir(p.Options.Root)
+	if err != nil {
+		p.logger.Error(err)
+		return
+	}
+
+	p.pidPath = fmt.Sprintf("%s/%s.pid", runtimeDir, p.Name)
+}
+
+// logPaths 返回进程标准输出和标准错误的日志文件路径，调用方需持有 p.mu
+func (p *Process) logPaths() (string, string, error) {
+	logDir := p.Options.LogRoot
+	if logDir == "" {
+		runtimeDir, err := config.GetRuntimeDir(p.Options.Root)
+		if err != nil {
+			return "", "", err
+		}
+		logDir = runtimeDir
+	}
+
+	return fmt.Sprintf("%s/%s_output.log", logDir, p.Name),
+		fmt.Sprintf("%s/%s_error.log", logDir, p.Name),
+		nil
+}
+
+// LogPaths 返回进程标准输出和标准错误的日志文件路径
+func (p *Process) LogPaths() (string, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.logPaths()
 }
 
 func (p *Process) SetLog() bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	logDir := config.GetRuntimeDir(p.Options.Root)
-	if p.Options.LogRoot != "" {
-		logDir = p.Options.LogRoot
+	outputLogPath, errorLogPath, err := p.logPaths()
+	if err != nil {
+		p.logger.Error(err)
+		return false
 	}
 
-	outputLogPath := fmt.Sprintf("%s/%s_output.log", logDir, p.Name)
-	errorLogPath := fmt.Sprintf("%s/%s_error.log", logDir, p.Name)
-
 	outLog, err := os.OpenFile(outputLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		p.logger.Error(err)
@@ -141,6 +246,9 @@ func (p *Process) SetLog() bool {
 		return false
 	}
 
+	p.chownToCredential(outputLogPath)
+	p.chownToCredential(errorLogPath)
+
 	p.OutLog = outLog
 	p.ErrLog = errLog
 
@@ -156,6 +264,16 @@ func (p *Process) IsRunning() bool {
 		return false
 	}
 
+	// 子进程已被回收，PID 可能已被系统复用，不能再用信号0判断
+	if p.exited != nil {
+		select {
+		case <-p.exited:
+			p.State = processStopped
+			return false
+		default:
+		}
+	}
+
 	if p.Pid > 0 {
 		process, err := os.FindProcess(p.Pid)
 		if err != nil {
@@ -174,6 +292,20 @@ func (p *Process) IsRunning() bool {
 	return true
 }
 
+// Signal 向运行中的进程发送信号
+func (p *Process) Signal(sig syscall.Signal) error {
+	if !p.IsRunning() {
+		return fmt.Errorf("process %s is not running", p.FullName)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.logger.Infof("Sending %v to %d", sig, p.Pid)
+
+	return p.sysproc.Signal(sig)
+}
+
 func (p *Process) Status() ProcessState {
 	return p.State
 }
@@ -199,14 +331,27 @@ func (p *Process) prepareEnvironment() error {
 		return fmt.Errorf("cannot open log files")
 	}
 
-	// 切换到进程的工作目录
-	if err := os.Chdir(p.Options.Root); err != nil {
-		return fmt.Errorf("cannot change to working directory %s: %w", p.Options.Root, err)
+	// 工作目录通过 cmd.Dir 设置，这里只检查目录是否可用；
+	// 定时任务和自动重启在计时器中启动，不能修改守护进程的当前目录
+	if info, err := os.Stat(p.Options.Root); err != nil {
+		return fmt.Errorf("cannot use working directory %s: %w", p.Options.Root, err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("cannot use working directory %s: not a directory", p.Options.Root)
 	}
 
 	return nil
 }
 
+// resolveExe 把包含路径分隔符的相对路径命令（例如 ./bin/worker）解析为 dir 下的路径，
+// 不包含分隔符的命令仍然从 PATH 中查找
+func resolveExe(exe, dir string) string {
+	if filepath.IsAbs(exe) || !strings.Contains(exe, "/") {
+		return exe
+	}
+
+	return filepath.Join(dir, exe)
+}
+
 // buildCommand 构建要执行的命令
 func (p *Process) buildCommand() (*exec.Cmd, error) {
 	task := p.Options.cmd
@@ -215,7 +360,7 @@ func (p *Process) buildCommand() (*exec.Cmd, error) {
 	}
 
 	// 解析命令和参数
-	exe := task[0]
+	exe := resolveExe(task[0], p.Options.Root)
 	var args []string
 	if len(task) > 1 {
 		args = task[1:]
@@ -228,32 +373,76 @@ func (p *Process) buildCommand() (*exec.Cmd, error) {
 
 	// 构建命令
 	cmd := exec.CommandContext(p.ctx, exe, args...)
-	cmd.WaitDelay = 2 * time.Second
 	cmd.Env = append(cmd.Env, p.Env...)
+	cmd.Dir = p.Options.Root
+
+	// 以配置的用户和组运行
+	attr, err := p.sysProcAttr()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// 设置了资源限制或调度选项时通过辅助模式启动，由辅助模式设置后切换用户再 exec
+	if len(p.Options.rlimits) > 0 || p.Options.sched != nil {
+		path, err := exec.LookPath(exe)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		var cred *syscall.Credential
+		if attr != nil {
+			cred = attr.Credential
+		}
+
+		task := append([]string{path}, args...)
+		cmd = exec.CommandContext(p.ctx, "/proc/self/exe", execHelperArgs(p.Options.rlimits, p.Options.sched, cred, task)...)
+		cmd.Env = append(cmd.Env, p.Env...)
+		cmd.Dir = p.Options.Root
+	} else {
+		cmd.SysProcAttr = attr
+	}
+
+	// Stop 取消上下文时发送配置的停止信号，超过 stopTimeout 仍未退出时由 exec 强制终止
+	sig := p.signal
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(sig)
+	}
+	cmd.WaitDelay = stopTimeout
+
+	// 前台模式下让进程使用单独的进程组，终端的 Ctrl-C 只发给 spm，由 spm 按停止信号依次停止
+	if config.ForegroundFlag {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Setpgid = true
+	}
 
 	return cmd, nil
 }
 
 // setupStreams 设置标准输出和错误输出的管道，并启动日志监控
-func (p *Process) setupStreams(cmd *exec.Cmd) error {
-	// 创建标准输出管道
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
+//
+// 输出经由 exec 的复制 goroutine 写入 io.Pipe，cmd.Wait 会等待进程退出前的输出全部复制完成，
+// 不会像 StdoutPipe 那样在 Wait 时关闭管道而丢失最后几行；
+// 返回的函数在 Wait 之后（或启动失败时）关闭管道，结束日志监控
+func (p *Process) setupStreams(cmd *exec.Cmd) func() {
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
 
-	// 创建标准错误管道
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
 
 	// 启动日志监控 goroutine
 	p.wg.Add(2)
-	go p.watchLog("STDOUT", stdoutPipe)
-	go p.watchLog("STDERR", stderrPipe)
+	go p.watchLog("STDOUT", stdoutR)
+	go p.watchLog("STDERR", stderrR)
 
-	return nil
+	return func() {
+		_ = stdoutW.Close()
+		_ = stderrW.Close()
+	}
 }
 
 // launchProcess 启动进程并记录状态
@@ -270,39 +459,103 @@ func (p *Process) launchProcess(cmd *exec.Cmd) error {
 
 	p.Pid = cmd.Process.Pid
 	p.sysproc = cmd.Process
+	p.exited = make(chan struct{})
+	p.stopping = false
 	p.StartAt = time.Now()
 	p.State = processRunning
+	p.starts++
 
-	// 写入PID文件
+	// 写入PID文件，定时任务的额外实例没有 PID 文件
+	if p.pidPath == "" {
+		return nil
+	}
 	if err := os.WriteFile(p.pidPath, []byte(strconv.Itoa(p.Pid)), 0644); err != nil {
 		p.logger.Error(err)
+	} else {
+		p.chownToCredential(p.pidPath)
 	}
 
 	return nil
 }
 
 // monitorProcess 在goroutine中监控进程，等待其结束并处理退出状态
-func (p *Process) monitorProcess(cmd *exec.Cmd) {
+func (p *Process) monitorProcess(cmd *exec.Cmd, exited chan struct{}, closeStreams func()) {
+	p.mu.Lock()
+	startAt := p.StartAt
+	p.mu.Unlock()
+
 	err := cmd.Wait()
+	closeStreams()
+	close(exited)
+
+	// 上下文被 Stop 取消后进程正常退出时，Wait 返回 ctx.Err()
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+
+	exitCode := 0
+	message := ""
+
 	if err != nil {
 		var exitErr *exec.ExitError
 		if !errors.As(err, &exitErr) {
 			p.logger.Error(err)
+			message = err.Error()
 		} else {
 			ws := exitErr.Sys().(syscall.WaitStatus)
 			if ws.Signaled() {
+				// 与 shell 的约定一致，被信号终止时退出码为 128+信号值
+				exitCode = 128 + int(ws.Signal())
 				p.logger.Infof("Process %s is stopped by signal: %v", p.Name, p.signal)
+				message = fmt.Sprintf("signal: %v", ws.Signal())
 			} else {
-				p.logger.Infof("Process %s exited with code=%d", p.Name, ws.ExitStatus())
+				exitCode = ws.ExitStatus()
+				p.logger.Infof("Process %s exited with code=%d", p.Name, exitCode)
 			}
 		}
 	}
 
-	p.StopAt = time.Now()
-	p.onStop()
+	stopAt := time.Now()
+
+	// 重启后 p.exited 已指向新进程，不能覆盖新进程的状态，也不能删除新进程的 PID 文件；
+	// 关闭 exited 之后并发的 IsRunning 会把状态改为 Stopped，因此用 Stop 设置的 stopping 区分进程是否自己退出。
+	// Stop 持有 p.mu 等待 exited 关闭，这里只能在关闭之后加锁
+	p.mu.Lock()
+	current := p.exited == exited
+	exitedItself := current && !p.stopping
+	if current {
+		p.StopAt = stopAt
+		p.onStop()
+	}
+	if exitedItself {
+		p.State = processStopped
+	}
+	p.exitCode = exitCode
+	p.hasExit = true
+	p.mu.Unlock()
+
+	publishEvent(p, EventExited, exitCode, message)
+
+	if p.job != nil {
+		p.onJobExit(startAt, stopAt, exitCode, message)
+	}
+
+	if exitedItself {
+		p.restartOnExit(exitCode, stopAt.Sub(startAt))
+	}
 }
 
+// Start 启动进程；配置了 schedule 的定时任务只启用调度，到点时再运行
 func (p *Process) Start() bool {
+	if p.IsJob() {
+		return p.armJob()
+	}
+
+	return p.start()
+}
+
+// start 立即启动进程
+func (p *Process) start() bool {
 	// 验证启动条件
 	if err := p.validateStart(); err != nil {
 		// 如果已经在运行，返回 true（这是预期行为）
@@ -326,27 +579,66 @@ func (p *Process) Start() bool {
 		return false
 	}
 
-	// 设置输出流管道
-	if err := p.setupStreams(cmd); err != nil {
+	// 放入进程的叶子 cgroup，进程启动后关闭目录文件描述符
+	cgroupFD, err := p.setupCgroup()
+	if err != nil {
+		p.cancel()
 		p.logger.Error(err)
 		return false
 	}
+	if cgroupFD >= 0 {
+		defer func() {
+			_ = unix.Close(cgroupFD)
+		}()
+
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = cgroupFD
+	}
+
+	// 设置输出流管道，配置了 tty 时在伪终端中运行
+	var closeStreams func()
+	if p.Options.Tty {
+		closeStreams, err = p.setupConsole(cmd)
+		if err != nil {
+			p.cancel()
+			p.logger.Error(err)
+			return false
+		}
+	} else {
+		closeStreams = p.setupStreams(cmd)
+	}
 
 	// 启动进程
 	if err := p.launchProcess(cmd); err != nil {
+		closeStreams()
 		p.logger.Error(err)
+		publishEvent(p, EventFailed, 0, err.Error())
 		return false
 	}
 
 	// 在后台监控进程
-	go p.monitorProcess(cmd)
+	go p.monitorProcess(cmd, p.exited, closeStreams)
 
 	p.logger.Infof("Process %s is started", p.Name)
+	publishEvent(p, EventStarted, 0, "")
 	return true
 }
 
+// Stop 停止进程；定时任务同时停用调度，并停止 allow 模式下的额外实例
 func (p *Process) Stop() bool {
-	if p.IsRunning() && !p.updatePid() {
+	p.cancelRestart()
+
+	if p.cloneID == 0 {
+		for _, clone := range p.disarmJob() {
+			clone.Stop()
+		}
+	}
+
+	// 定时任务的额外实例没有 PID 文件，不需要从 PID 文件更新
+	if p.IsRunning() && p.pidPath != "" && !p.updatePid() {
 		p.State = processUnknown
 	}
 
@@ -361,20 +653,30 @@ func (p *Process) Stop() bool {
 				return false
 			}
 
-			p.cancel()
-			p.wg.Wait()
-
 			p.logger.Infof("Sending %s to %d", p.Options.StopSignal, p.Pid)
 			p.State = processStopping
+			p.stopping = true
 
-			err := p.sysproc.Signal(p.signal)
-			if err != nil && !errors.Is(err, os.ErrProcessDone) {
-				p.logger.Error(err)
-				_ = p.sysproc.Kill()
-				p.State = processStopped
-			} else {
-				p.State = processStopped
+			// 取消上下文时由 cmd.Cancel 发送停止信号
+			p.cancel()
+
+			// 等待子进程被回收，避免随后的 Start 把僵尸进程误判为运行中
+			if p.exited != nil {
+				select {
+				case <-p.exited:
+				case <-time.After(stopTimeout):
+					p.logger.Warnf("Process %s did not exit in %s, killing it", p.Name, stopTimeout)
+					_ = p.sysproc.Kill()
+				}
 			}
+			p.wg.Wait()
+
+			// 终止主进程派生的、仍在 cgroup 中运行的子进程
+			p.cleanupCgroup()
+
+			p.State = processStopped
+			p.StopAt = time.Now()
+			publishEvent(p, EventStopped, 0, "")
 		}
 	case processStopped:
 		p.logger.Infof("Process %s already stopped", p.Name)
@@ -431,9 +733,16 @@ func (p *Process) updatePid() bool {
 	return true
 }
 
+// onStop 清除启动时间并删除 PID 文件，调用方需持有 p.mu
+//
+// PID 文件已经属于其他进程时保留，例如同名的 spm run 进程替换了已经停止的进程
 func (p *Process) onStop() {
 	p.StartAt = time.Time{}
 
+	if pid, err := utils.ReadPid(p.pidPath); err == nil && pid != p.Pid {
+		return
+	}
+
 	err := os.Remove(p.pidPath)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
@@ -447,11 +756,18 @@ func (p *Process) onStop() {
 func (p *Process) watchLog(logtype string, r io.ReadCloser) {
 	defer p.wg.Done()
 
-	tty := os.Stdout
 	dest := p.OutLog
 	if logtype == "STDERR" {
 		dest = p.ErrLog
-		tty = os.Stderr
+	}
+
+	// 前台模式下输出的进程名，定时任务的额外实例带上编号
+	label := p.Name
+	if p.cloneID > 0 {
+		label = fmt.Sprintf("%s.%d", p.Name, p.cloneID)
+	}
+	if config.ForegroundFlag {
+		foreground.register(label)
 	}
 
 	defer func() {
@@ -465,9 +781,12 @@ func (p *Process) watchLog(logtype string, r io.ReadCloser) {
 
 	scanner := bufio.NewScanner(tee)
 	for scanner.Scan() {
-		line := scanner.Text()
+		// 伪终端的输出以 "\r\n" 换行
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		publishLog(p.FullName, strings.ToLower(logtype), line)
+
 		if config.ForegroundFlag {
-			_, _ = fmt.Fprintf(tty, "%s\n", line)
+			foreground.writeLine(label, logtype, line)
 		}
 	}
 
@@ -0,0 +1,81 @@
+// Package supervisor 提供从 /proc 读取进程资源占用的功能
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// clockTicks /proc/<pid>/stat 中 CPU 时间的单位（USER_HZ），Linux 上固定为 100
+const clockTicks = 100
+
+// ProcUsage 进程的资源占用
+//
+// 没有启用 cgroup 时只统计主进程；启用 cgroup 时 CPU 时间、内存和进程数按整个叶子 cgroup 统计，
+// 包含主进程派生的所有子进程，文件描述符和线程数仍只统计主进程
+type ProcUsage struct {
+	CPUSeconds float64 `json:"cpu_seconds"`     // 用户态和内核态 CPU 时间之和
+	RSSBytes   int64   `json:"rss_bytes"`       // 常驻内存，启用 cgroup 时为 memory.current
+	FDs        int     `json:"fds"`             // 打开的文件描述符数量，无权限读取时为 -1
+	Threads    int     `json:"threads"`         // 线程数
+	Procs      int     `json:"procs,omitempty"` // cgroup 中的进程数，没有启用 cgroup 时为 0
+}
+
+// readProcUsage 从 /proc/<pid>/stat 和 /proc/<pid>/fd 读取进程的资源占用
+func readProcUsage(pid int) (*ProcUsage, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	// 进程名（第 2 个字段）可能包含空格和括号，从最后一个右括号之后开始解析
+	stat := string(data)
+	idx := strings.LastIndexByte(stat, ')')
+	if idx < 0 {
+		return nil, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+
+	// fields[0] 对应 stat 的第 3 个字段（state）
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+
+	usage := &ProcUsage{
+		CPUSeconds: float64(utime+stime) / clockTicks,
+		RSSBytes:   rss * int64(os.Getpagesize()),
+		FDs:        -1,
+		Threads:    threads,
+	}
+
+	if entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid)); err == nil {
+		usage.FDs = len(entries)
+	}
+
+	return usage, nil
+}
+
+// Usage 读取运行中进程的资源占用，进程未运行时返回 nil
+func (p *Process) Usage() *ProcUsage {
+	if p.State != processRunning || p.Pid <= 0 {
+		return nil
+	}
+
+	usage, err := readProcUsage(p.Pid)
+	if err != nil {
+		return nil
+	}
+
+	if leaf := p.cgroupPath(); leaf != "" {
+		readCgroupUsage(leaf, usage)
+	}
+
+	return usage
+}
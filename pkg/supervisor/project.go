@@ -47,6 +47,13 @@ func (p *Project) SetState(name string, state bool) {
 	p.running[name] = state
 }
 
+func (p *Project) Remove(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.running, name)
+}
+
 func (p *Project) GetProcNames() []string {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -2,53 +2,256 @@
 package supervisor
 
 import (
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+
 	"spm/pkg/config"
 	"spm/pkg/utils"
 )
 
-// Reload 重新加载配置并返回变更的进程信息
+// Reload 重新加载全局配置并返回发生变更的进程信息
 //
 // 参数：
 //
-//	changed: 发生变更的进程列表
+//	changes: UpdateApp 返回的进程变更列表
 //
 // 返回：
 //
-//	[]*ProcInfo: 进程信息列表
-//
-// 功能：
-//  1. 重新加载配置文件
-//  2. 将变更的进程信息转换为 ProcInfo 格式
+//	[]*ProcInfo: 新增、变更和移除的进程信息，不包含未变化的进程
 //
 // 注意事项：
 //  1. 配置文件路径来自 utils.GlobalConfigFile
-//  2. 仅转换进程信息，不执行实际的启动/停止操作
+//  2. 仅转换进程信息，进程的启动/停止已由 UpdateApp 完成
 //
 // 示例：
 //
-//	infos := sv.Reload(changedProcs)
+//	_, changes := sv.UpdateApp(false, opt)
+//	infos := sv.Reload(changes)
 //	for _, info := range infos {
 //	    fmt.Printf("进程 %s 已变更\n", info.Name)
 //	}
 //
 // 创建时间: 2025-12-06
-func (sv *Supervisor) Reload(changed []*Process) []*ProcInfo {
+func (sv *Supervisor) Reload(changes []*ProcChange) []*ProcInfo {
 	sv.logger.Info("Reloading configuration")
-	config.SetConfig(utils.GlobalConfigFile)
+	if err := config.SetConfig(utils.GlobalConfigFile); err != nil {
+		sv.logger.Errorf("Keep previous configuration: %v", err)
+	}
 
 	pInfo := make([]*ProcInfo, 0)
 
-	if len(changed) > 0 {
-		for _, p := range changed {
-			pInfo = append(pInfo, &ProcInfo{
-				Pid:     p.Pid,
-				Name:    p.FullName,
-				StartAt: p.StartAt.UnixMilli(),
-				StopAt:  p.StopAt.UnixMilli(),
-				Status:  p.State,
-			})
+	for _, c := range changes {
+		if c.Change == ChangeUnchanged || c.proc == nil {
+			continue
 		}
+
+		p := c.proc
+		pInfo = append(pInfo, &ProcInfo{
+			Pid:     p.Pid,
+			Name:    p.FullName,
+			StartAt: p.StartAt.UnixMilli(),
+			StopAt:  p.StopAt.UnixMilli(),
+			Status:  p.State,
+		})
 	}
 
 	return pInfo
 }
+
+// PlanReload 计算重新加载配置后每个进程将发生的变更，不会修改任何进程
+//
+// 参数：
+//
+//	procOpts: 新加载的 Procfile 配置
+//
+// 返回：
+//
+//	[]*ProcChange: 变更计划，项目未注册时返回 nil
+//
+// 示例：
+//
+//	for _, c := range sv.PlanReload(opt) {
+//	    fmt.Printf("%s %s\n", c.Action, c.Name)
+//	}
+func (sv *Supervisor) PlanReload(procOpts *ProcfileOption) []*ProcChange {
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+
+	proj := sv.projectTable.Get(procOpts.AppName)
+	if proj == nil {
+		return nil
+	}
+
+	changes := sv.planReload(proj, procOpts)
+	for _, c := range changes {
+		c.fill(sv.procTable.Get(c.Name))
+	}
+
+	return changes
+}
+
+// planReload 对比项目当前的进程配置和新加载的配置，计算每个进程的变更及要执行的操作
+//
+// 参数：
+//
+//	proj: 当前已注册的项目
+//	procOpts: 新加载的 Procfile 配置
+//
+// 返回：
+//
+//	[]*ProcChange: 按进程名排序的变更列表，不修改任何进程
+func (sv *Supervisor) planReload(proj *Project, procOpts *ProcfileOption) []*ProcChange {
+	changes := make([]*ProcChange, 0)
+	projRunning := false
+
+	for _, name := range proj.GetProcNames() {
+		fullName := fmt.Sprintf("%s::%s", proj.Name, name)
+		proc := sv.procTable.Get(fullName)
+		// spm run 注册的临时进程不在 Procfile 中，由 spm rm 移除
+		if proc == nil || proc.IsAdhoc() {
+			continue
+		}
+
+		running := proc.IsRunning()
+		projRunning = projRunning || running
+
+		newOpt, ok := procOpts.Processes[name]
+		if !ok {
+			action := ReloadRemove
+			if running {
+				action = ReloadStop
+			}
+			changes = append(changes, &ProcChange{Name: fullName, Change: ChangeRemoved, Action: action})
+			continue
+		}
+
+		fields := diffProcessOption(proc.Options, newOpt)
+		if len(fields) > 0 {
+			action := ReloadUpdate
+			if running {
+				action = ReloadRestart
+			}
+			changes = append(changes, &ProcChange{Name: fullName, Change: ChangeChanged, Action: action, Fields: fields})
+		} else {
+			changes = append(changes, &ProcChange{Name: fullName, Change: ChangeUnchanged, Action: ReloadNone})
+		}
+	}
+
+	// 项目正在运行时，新增的进程随 reload 一起启动
+	addAction := ReloadRegister
+	if projRunning {
+		addAction = ReloadStart
+	}
+
+	for name := range procOpts.Processes {
+		if !proj.IsExist(name) {
+			fullName := fmt.Sprintf("%s::%s", proj.Name, name)
+			changes = append(changes, &ProcChange{Name: fullName, Change: ChangeAdded, Action: addAction})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
+
+// diffProcessOption 返回两份进程配置中发生变化的字段（字段名与 Procfile.options 中的键一致）
+//
+// 环境变量只列出变化的变量名，不输出变量值，避免泄露敏感信息
+func diffProcessOption(oldOpt, newOpt *ProcessOption) []*FieldDiff {
+	fields := make([]*FieldDiff, 0)
+
+	addField := func(name, oldVal, newVal string) {
+		if oldVal != newVal {
+			fields = append(fields, &FieldDiff{Field: name, Old: oldVal, New: newVal})
+		}
+	}
+
+	addField("command", strings.Join(oldOpt.cmd, " "), strings.Join(newOpt.cmd, " "))
+
+	if !maps.Equal(oldOpt.Env, newOpt.Env) {
+		fields = append(fields, &FieldDiff{Field: "env", New: diffEnvKeys(oldOpt.Env, newOpt.Env)})
+	}
+
+	addField("root", oldOpt.Root, newOpt.Root)
+	addField("pidRoot", oldOpt.PidRoot, newOpt.PidRoot)
+	addField("logRoot", oldOpt.LogRoot, newOpt.LogRoot)
+	addField("stopSignal", oldOpt.StopSignal, newOpt.StopSignal)
+	addField("numProcs", strconv.Itoa(oldOpt.NumProcs), strconv.Itoa(newOpt.NumProcs))
+	addField("user", oldOpt.User, newOpt.User)
+	addField("group", oldOpt.Group, newOpt.Group)
+	addField("groups", strings.Join(oldOpt.Groups, ","), strings.Join(newOpt.Groups, ","))
+	addField("rlimits", rlimitsString(oldOpt.rlimits), rlimitsString(newOpt.rlimits))
+	addField("memoryMax", oldOpt.MemoryMax, newOpt.MemoryMax)
+	addField("cpuMax", oldOpt.CPUMax, newOpt.CPUMax)
+	addField("pidsMax", oldOpt.PidsMax, newOpt.PidsMax)
+	addField("ioWeight", strconv.Itoa(oldOpt.IOWeight), strconv.Itoa(newOpt.IOWeight))
+	// 项目级的限制没有对应的进程字段，以解析后的 cgroup 接口文件的值比较
+	addField("project", cgroupLimitsString(oldOpt.projectCgroup), cgroupLimitsString(newOpt.projectCgroup))
+	addField("schedule", oldOpt.Schedule, newOpt.Schedule)
+	addField("overlap", string(oldOpt.overlap), string(newOpt.overlap))
+	addField("tty", strconv.FormatBool(oldOpt.Tty), strconv.FormatBool(newOpt.Tty))
+	addField("sched", oldOpt.sched.String(), newOpt.sched.String())
+	addField("watchdog", oldOpt.watchdog.String(), newOpt.watchdog.String())
+	addField("watch", oldOpt.watch.String(), newOpt.watch.String())
+
+	return fields
+}
+
+// diffEnvKeys 以 "+新增 ~修改 -删除" 的形式描述环境变量的变化
+func diffEnvKeys(oldEnv, newEnv map[string]string) string {
+	keys := make([]string, 0)
+
+	for k, v := range newEnv {
+		if ov, ok := oldEnv[k]; !ok {
+			keys = append(keys, "+"+k)
+		} else if ov != v {
+			keys = append(keys, "~"+k)
+		}
+	}
+
+	for k := range oldEnv {
+		if _, ok := newEnv[k]; !ok {
+			keys = append(keys, "-"+k)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][1:] < keys[j][1:]
+	})
+
+	return strings.Join(keys, " ")
+}
+
+// summarizeChanges 统计变更计划中各类操作的数量，用于 dry-run 的响应消息
+func summarizeChanges(changes []*ProcChange) string {
+	counts := make(map[ReloadAction]int)
+	for _, c := range changes {
+		counts[c.Action]++
+	}
+
+	return fmt.Sprintf(
+		"Reload plan: %d to start, %d to restart, %d to stop, %d to update, %d unchanged",
+		counts[ReloadStart]+counts[ReloadRegister],
+		counts[ReloadRestart],
+		counts[ReloadStop]+counts[ReloadRemove],
+		counts[ReloadUpdate],
+		counts[ReloadNone],
+	)
+}
+
+// fill 记录进程在变更处理之后的状态
+func (c *ProcChange) fill(p *Process) {
+	if p == nil {
+		return
+	}
+
+	c.proc = p
+	c.Pid = p.Pid
+	c.Status = p.State
+}
@@ -0,0 +1,171 @@
+package supervisor
+
+import (
+	"os"
+	"testing"
+)
+
+// newTestProcess 创建不经过 NewProcess 的进程实例，running 为 true 时以测试进程自身作为运行中的进程
+func newTestProcess(t *testing.T, fullName string, opt *ProcessOption, running bool) *Process {
+	t.Helper()
+
+	p := &Process{Pid: -1, FullName: fullName, Options: opt, State: processStandby}
+	if running {
+		proc, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Pid = os.Getpid()
+		p.sysproc = proc
+	}
+
+	return p
+}
+
+func TestPlanReload(t *testing.T) {
+	opt := func(cmd string, env map[string]string) *ProcessOption {
+		return &ProcessOption{cmd: []string{cmd}, Env: env, NumProcs: 1}
+	}
+
+	tests := []struct {
+		name    string
+		running map[string]bool           // 当前注册的进程及是否运行
+		old     map[string]*ProcessOption // 当前的配置
+		new     map[string]*ProcessOption // 新加载的配置
+		want    map[string]ReloadAction   // 进程名到操作
+		changes map[string]ChangeType     // 进程名到变更类型
+	}{
+		{
+			name:    "unchanged",
+			running: map[string]bool{"web": true},
+			old:     map[string]*ProcessOption{"web": opt("web", nil)},
+			new:     map[string]*ProcessOption{"web": opt("web", nil)},
+			want:    map[string]ReloadAction{"web": ReloadNone},
+			changes: map[string]ChangeType{"web": ChangeUnchanged},
+		},
+		{
+			name:    "changed and running",
+			running: map[string]bool{"web": true},
+			old:     map[string]*ProcessOption{"web": opt("web", nil)},
+			new:     map[string]*ProcessOption{"web": opt("web --port 80", nil)},
+			want:    map[string]ReloadAction{"web": ReloadRestart},
+			changes: map[string]ChangeType{"web": ChangeChanged},
+		},
+		{
+			name:    "changed and stopped",
+			running: map[string]bool{"web": false},
+			old:     map[string]*ProcessOption{"web": opt("web", map[string]string{"A": "1"})},
+			new:     map[string]*ProcessOption{"web": opt("web", map[string]string{"A": "2"})},
+			want:    map[string]ReloadAction{"web": ReloadUpdate},
+			changes: map[string]ChangeType{"web": ChangeChanged},
+		},
+		{
+			name:    "removed",
+			running: map[string]bool{"web": true, "worker": false},
+			old:     map[string]*ProcessOption{"web": opt("web", nil), "worker": opt("worker", nil)},
+			new:     map[string]*ProcessOption{},
+			want:    map[string]ReloadAction{"web": ReloadStop, "worker": ReloadRemove},
+			changes: map[string]ChangeType{"web": ChangeRemoved, "worker": ChangeRemoved},
+		},
+		{
+			name:    "added to a running project",
+			running: map[string]bool{"web": true},
+			old:     map[string]*ProcessOption{"web": opt("web", nil)},
+			new:     map[string]*ProcessOption{"web": opt("web", nil), "worker": opt("worker", nil)},
+			want:    map[string]ReloadAction{"web": ReloadNone, "worker": ReloadStart},
+			changes: map[string]ChangeType{"web": ChangeUnchanged, "worker": ChangeAdded},
+		},
+		{
+			name:    "added to a stopped project",
+			running: map[string]bool{"web": false},
+			old:     map[string]*ProcessOption{"web": opt("web", nil)},
+			new:     map[string]*ProcessOption{"web": opt("web", nil), "worker": opt("worker", nil)},
+			want:    map[string]ReloadAction{"web": ReloadNone, "worker": ReloadRegister},
+			changes: map[string]ChangeType{"web": ChangeUnchanged, "worker": ChangeAdded},
+		},
+		{
+			name:    "adhoc process is left alone",
+			running: map[string]bool{"web": false, "shell": true},
+			old:     map[string]*ProcessOption{"web": opt("web", nil), "shell": {cmd: []string{"sh"}, adhoc: true}},
+			new:     map[string]*ProcessOption{"web": opt("web", nil)},
+			want:    map[string]ReloadAction{"web": ReloadNone},
+			changes: map[string]ChangeType{"web": ChangeUnchanged},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &Supervisor{
+				projectTable: &ProjectTable{table: make(map[string]*Project)},
+				procTable:    &ProcTable{table: make(map[string]*Process)},
+			}
+
+			proj := CreateProject(&ProcfileOption{AppName: "app", Processes: tt.old})
+			for name, running := range tt.running {
+				fullName := "app::" + name
+				sv.procTable.Add(fullName, newTestProcess(t, fullName, tt.old[name], running))
+			}
+
+			changes := sv.planReload(proj, &ProcfileOption{AppName: "app", Processes: tt.new})
+			if len(changes) != len(tt.want) {
+				t.Fatalf("planReload() returned %d changes, want %d", len(changes), len(tt.want))
+			}
+
+			for i, c := range changes {
+				if i > 0 && changes[i-1].Name >= c.Name {
+					t.Errorf("changes are not sorted: %s before %s", changes[i-1].Name, c.Name)
+				}
+
+				name := c.Name[len("app::"):]
+				if c.Action != tt.want[name] {
+					t.Errorf("%s action = %s, want %s", name, c.Action, tt.want[name])
+				}
+				if c.Change != tt.changes[name] {
+					t.Errorf("%s change = %s, want %s", name, c.Change, tt.changes[name])
+				}
+			}
+		})
+	}
+}
+
+func TestDiffProcessOption(t *testing.T) {
+	oldOpt := &ProcessOption{
+		cmd:      []string{"web", "--port", "80"},
+		Env:      map[string]string{"KEEP": "1", "CHANGE": "a", "DROP": "x"},
+		Root:     "/srv/app",
+		NumProcs: 1,
+		CgroupOption: CgroupOption{
+			MemoryMax: "512M",
+		},
+	}
+	newOpt := &ProcessOption{
+		cmd:      []string{"web", "--port", "8080"},
+		Env:      map[string]string{"KEEP": "1", "CHANGE": "b", "ADD": "y"},
+		Root:     "/srv/app",
+		NumProcs: 2,
+		CgroupOption: CgroupOption{
+			MemoryMax: "1G",
+		},
+	}
+
+	want := []FieldDiff{
+		{Field: "command", Old: "web --port 80", New: "web --port 8080"},
+		{Field: "env", New: "+ADD ~CHANGE -DROP"},
+		{Field: "numProcs", Old: "1", New: "2"},
+		{Field: "memoryMax", Old: "512M", New: "1G"},
+	}
+
+	got := diffProcessOption(oldOpt, newOpt)
+	if len(got) != len(want) {
+		t.Fatalf("diffProcessOption() returned %d fields, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+
+	if fields := diffProcessOption(oldOpt, oldOpt); len(fields) != 0 {
+		t.Errorf("diffProcessOption() of the same option = %+v, want none", fields)
+	}
+}
@@ -0,0 +1,342 @@
+// Package supervisor 提供进程资源限制（rlimit）的解析和设置功能
+package supervisor
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ExecHelperArg 进程启动辅助模式的命令行参数
+//
+// Go 无法在 fork 之后、exec 之前执行代码，设置了 rlimits 或调度选项的进程先以该参数启动 spm 自身，
+// 由辅助模式设置资源限制和调度选项、切换用户后再 exec 真正的命令，PID 保持不变
+const ExecHelperArg = "__spm-exec"
+
+// rlimitResources rlimits 中可以使用的资源名称，与 ulimit/prlimit 的名称一致
+var rlimitResources = map[string]int{
+	"as":         unix.RLIMIT_AS,
+	"core":       unix.RLIMIT_CORE,
+	"cpu":        unix.RLIMIT_CPU,
+	"data":       unix.RLIMIT_DATA,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"locks":      unix.RLIMIT_LOCKS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"rss":        unix.RLIMIT_RSS,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"stack":      unix.RLIMIT_STACK,
+}
+
+// rlimitSetting 解析后的单项资源限制
+type rlimitSetting struct {
+	name     string
+	resource int
+	limit    unix.Rlimit
+}
+
+// rlimitNames 返回所有支持的资源名称，按字母排序
+func rlimitNames() []string {
+	names := make([]string, 0, len(rlimitResources))
+	for name := range rlimitResources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// resolveRlimits 解析进程配置中的 rlimits
+//
+// 参数：
+//
+//	limits: 资源名称到限制值的映射，键不区分大小写
+//
+// 返回：
+//
+//	[]rlimitSetting: 按资源名称排序的限制，limits 为空时返回 nil
+//	error: 未知的资源名称或无效的限制值
+//
+// 限制值的格式：
+//   - "4096"：软限制和硬限制相同
+//   - "1024:4096"：软限制:硬限制
+//   - "unlimited" 或 "infinity"：不限制
+//   - 数字可以带 K、M、G、T 后缀（1024 进制），适用于 as、data、stack 等以字节为单位的资源
+func resolveRlimits(limits map[string]string) ([]rlimitSetting, error) {
+	if len(limits) == 0 {
+		return nil, nil
+	}
+
+	settings := make([]rlimitSetting, 0, len(limits))
+	for name, value := range limits {
+		name = strings.ToLower(name)
+
+		resource, ok := rlimitResources[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown rlimit %q, supported: %s", name, strings.Join(rlimitNames(), " "))
+		}
+
+		limit, err := parseRlimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("rlimit %s: %w", name, err)
+		}
+
+		settings = append(settings, rlimitSetting{name: name, resource: resource, limit: limit})
+	}
+
+	slices.SortFunc(settings, func(a, b rlimitSetting) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	return settings, nil
+}
+
+// parseRlimit 解析 "软限制:硬限制" 或单个值形式的限制，软限制不能大于硬限制
+func parseRlimit(value string) (unix.Rlimit, error) {
+	softStr, hardStr, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found {
+		hardStr = softStr
+	}
+
+	soft, err := parseRlimitValue(softStr)
+	if err != nil {
+		return unix.Rlimit{}, err
+	}
+
+	hard, err := parseRlimitValue(hardStr)
+	if err != nil {
+		return unix.Rlimit{}, err
+	}
+
+	if soft > hard {
+		return unix.Rlimit{}, fmt.Errorf("soft limit %s is greater than hard limit %s", softStr, hardStr)
+	}
+
+	return unix.Rlimit{Cur: soft, Max: hard}, nil
+}
+
+// parseRlimitValue 解析单个限制值
+func parseRlimitValue(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+
+	switch strings.ToLower(s) {
+	case "unlimited", "infinity":
+		return unix.RLIM_INFINITY, nil
+	case "":
+		return 0, fmt.Errorf("empty limit")
+	}
+
+	return parseSize(s)
+}
+
+// parseSize 解析可以带 K、M、G、T 后缀（1024 进制）的非负整数
+func parseSize(s string) (uint64, error) {
+	multiplier := uint64(1)
+	if n := len(s); n > 1 {
+		if i := strings.IndexByte("KMGT", s[n-1]&^0x20); i >= 0 {
+			multiplier = 1 << (10 * (i + 1))
+			s = s[:n-1]
+		}
+	}
+
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+
+	if v > math.MaxUint64/multiplier {
+		return 0, fmt.Errorf("value %q overflows", s)
+	}
+
+	return v * multiplier, nil
+}
+
+// formatRlimit 把限制格式化为配置文件中的形式，软硬限制相同时只输出一个值
+func formatRlimit(limit unix.Rlimit) string {
+	format := func(v uint64) string {
+		if v == unix.RLIM_INFINITY {
+			return "unlimited"
+		}
+		return strconv.FormatUint(v, 10)
+	}
+
+	if limit.Cur == limit.Max {
+		return format(limit.Cur)
+	}
+
+	return format(limit.Cur) + ":" + format(limit.Max)
+}
+
+// rlimitsString 把资源限制格式化为 "nofile=1024:4096 core=0" 的形式，用于 reload 时比较
+func rlimitsString(settings []rlimitSetting) string {
+	parts := make([]string, 0, len(settings))
+	for _, s := range settings {
+		parts = append(parts, s.name+"="+formatRlimit(s.limit))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// effectiveRlimits 返回进程配置的各项资源限制
+//
+// 进程运行时通过 prlimit 读取实际生效的值，否则返回配置的值；没有配置时返回 nil
+func effectiveRlimits(pid int, settings []rlimitSetting) map[string]string {
+	if len(settings) == 0 {
+		return nil
+	}
+
+	limits := make(map[string]string, len(settings))
+	for _, s := range settings {
+		limit := s.limit
+		if pid > 0 {
+			var current unix.Rlimit
+			if err := unix.Prlimit(pid, s.resource, nil, &current); err == nil {
+				limit = current
+			}
+		}
+		limits[s.name] = formatRlimit(limit)
+	}
+
+	return limits
+}
+
+// execHelperArgs 生成以辅助模式启动进程的参数，cred 不为 nil 时由辅助模式切换用户
+func execHelperArgs(settings []rlimitSetting, sched *schedSetting, cred *syscall.Credential, task []string) []string {
+	args := []string{ExecHelperArg}
+
+	for _, s := range settings {
+		args = append(args, "-rlimit", fmt.Sprintf("%s=%d:%d", s.name, s.limit.Cur, s.limit.Max))
+	}
+
+	args = append(args, schedHelperArgs(sched)...)
+
+	if cred != nil {
+		groups := make([]string, 0, len(cred.Groups))
+		for _, g := range cred.Groups {
+			groups = append(groups, strconv.FormatUint(uint64(g), 10))
+		}
+
+		args = append(args,
+			"-uid", strconv.FormatUint(uint64(cred.Uid), 10),
+			"-gid", strconv.FormatUint(uint64(cred.Gid), 10),
+			"-groups", strings.Join(groups, ","),
+		)
+		if cred.NoSetGroups {
+			args = append(args, "-no-setgroups")
+		}
+	}
+
+	return append(append(args, "--"), task...)
+}
+
+// ExecHelper 辅助模式的入口，设置资源限制和调度选项、切换用户后 exec 目标命令，不会返回
+//
+// 失败时在标准错误输出原因并以 127 退出，错误信息会写入进程的错误日志
+func ExecHelper(args []string) {
+	if err := execHelper(args); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "spm: %v\n", err)
+		os.Exit(127)
+	}
+}
+
+func execHelper(args []string) error {
+	// nice、ioprio 和 CPU 亲和性都是线程级的设置，必须在同一个线程中设置并 exec
+	runtime.LockOSThread()
+
+	fs := flag.NewFlagSet(ExecHelperArg, flag.ContinueOnError)
+
+	var limits []string
+	fs.Func("rlimit", "name=soft:hard", func(s string) error {
+		limits = append(limits, s)
+		return nil
+	})
+	uid := fs.Int("uid", -1, "")
+	gid := fs.Int("gid", -1, "")
+	groups := fs.String("groups", "", "")
+	noSetGroups := fs.Bool("no-setgroups", false, "")
+
+	var nice, ioprio, oomScoreAdj *int
+	intFlag := func(name string, target **int) {
+		fs.Func(name, "", func(s string) error {
+			n, err := strconv.Atoi(s)
+			*target = &n
+			return err
+		})
+	}
+	intFlag("nice", &nice)
+	intFlag("ioprio", &ioprio)
+	intFlag("oom-score-adj", &oomScoreAdj)
+	cpus := fs.String("cpus", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	task := fs.Args()
+	if len(task) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+
+	// 先以守护进程的权限设置资源限制和调度选项，root 可以提高硬限制和优先级
+	for _, l := range limits {
+		name, value, _ := strings.Cut(l, "=")
+
+		limit, err := parseRlimit(value)
+		if err != nil {
+			return fmt.Errorf("rlimit %s: %w", name, err)
+		}
+
+		// 使用 syscall.Setrlimit，Go 运行时在 exec 时不会再恢复启动前的 nofile 软限制
+		rlim := syscall.Rlimit{Cur: limit.Cur, Max: limit.Max}
+		if err := syscall.Setrlimit(rlimitResources[name], &rlim); err != nil {
+			return fmt.Errorf("setrlimit %s %s: %w", name, formatRlimit(limit), err)
+		}
+	}
+
+	if err := applySched(nice, ioprio, oomScoreAdj, *cpus); err != nil {
+		return err
+	}
+
+	// 再按 setgroups、setgid、setuid 的顺序切换用户，切换后无法再修改附加组和组
+	if *uid >= 0 {
+		if !*noSetGroups {
+			gids := make([]int, 0)
+			for _, g := range strings.Split(*groups, ",") {
+				if n, err := strconv.Atoi(g); err == nil {
+					gids = append(gids, n)
+				}
+			}
+			if err := syscall.Setgroups(gids); err != nil {
+				return fmt.Errorf("setgroups: %w", err)
+			}
+		}
+
+		if *gid >= 0 && *gid != os.Getgid() {
+			if err := syscall.Setgid(*gid); err != nil {
+				return fmt.Errorf("setgid %d: %w", *gid, err)
+			}
+		}
+
+		if *uid != os.Getuid() {
+			if err := syscall.Setuid(*uid); err != nil {
+				return fmt.Errorf("setuid %d: %w", *uid, err)
+			}
+		}
+	}
+
+	// 命令路径已由守护进程按 PATH 解析
+	return syscall.Exec(task[0], task, os.Environ())
+}
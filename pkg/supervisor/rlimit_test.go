@@ -0,0 +1,66 @@
+package supervisor
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseRlimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  unix.Rlimit
+	}{
+		{value: "4096", want: unix.Rlimit{Cur: 4096, Max: 4096}},
+		{value: "1024:4096", want: unix.Rlimit{Cur: 1024, Max: 4096}},
+		{value: " 1024 : 4096 ", want: unix.Rlimit{Cur: 1024, Max: 4096}},
+		{value: "0", want: unix.Rlimit{Cur: 0, Max: 0}},
+		{value: "unlimited", want: unix.Rlimit{Cur: unix.RLIM_INFINITY, Max: unix.RLIM_INFINITY}},
+		{value: "1024:infinity", want: unix.Rlimit{Cur: 1024, Max: unix.RLIM_INFINITY}},
+		{value: "8M", want: unix.Rlimit{Cur: 8 << 20, Max: 8 << 20}},
+		{value: "1g:2G", want: unix.Rlimit{Cur: 1 << 30, Max: 2 << 30}},
+		{value: "1K", want: unix.Rlimit{Cur: 1024, Max: 1024}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRlimit(tt.value)
+			if err != nil {
+				t.Fatalf("parseRlimit(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseRlimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRlimitErrors(t *testing.T) {
+	for _, value := range []string{"", ":", "abc", "-1", "4096:1024", "unlimited:1024", "1X", "16777216T"} {
+		if _, err := parseRlimit(value); err == nil {
+			t.Errorf("parseRlimit(%q) expected error", value)
+		}
+	}
+}
+
+func TestResolveRlimits(t *testing.T) {
+	settings, err := resolveRlimits(map[string]string{"NOFILE": "1024:4096", "core": "0"})
+	if err != nil {
+		t.Fatalf("resolveRlimits() error = %v", err)
+	}
+
+	if got, want := rlimitsString(settings), "core=0 nofile=1024:4096"; got != want {
+		t.Errorf("rlimitsString() = %q, want %q", got, want)
+	}
+	if settings[1].resource != unix.RLIMIT_NOFILE {
+		t.Errorf("nofile resource = %d, want %d", settings[1].resource, unix.RLIMIT_NOFILE)
+	}
+
+	if settings, err := resolveRlimits(nil); settings != nil || err != nil {
+		t.Errorf("resolveRlimits(nil) = %v, %v, want nil, nil", settings, err)
+	}
+
+	if _, err := resolveRlimits(map[string]string{"files": "10"}); err == nil {
+		t.Error("resolveRlimits() with unknown resource expected error")
+	}
+}
@@ -0,0 +1,350 @@
+// Package supervisor 提供 spm run 临时进程的注册、自动重启和移除功能
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"spm/pkg/config"
+)
+
+const (
+	restartMinDelay   = time.Second      // 第一次自动重启前的等待时间
+	restartMaxDelay   = time.Minute      // 连续快速退出时等待时间的上限
+	restartResetAfter = 10 * time.Second // 运行超过这个时间后退出，等待时间重新从 restartMinDelay 开始
+)
+
+var (
+	errProcessNotFound = errors.New("process not found")
+	errProcessNotAdhoc = errors.New("process is defined in the Procfile, remove it from the Procfile and reload instead")
+)
+
+// runNamePattern 临时进程名的格式，与 Procfile 中的进程名一致
+var runNamePattern = regexp.MustCompile(`^[A-Za-z]+[A-Za-z0-9-_]+$`)
+
+// RestartPolicy 临时进程自己退出后的重启策略，由 spm stop 停止时不会重启
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"      // 不重启（默认）
+	RestartOnFailure RestartPolicy = "on-failure" // 退出码不为 0 时重启
+	RestartAlways    RestartPolicy = "always"     // 总是重启
+)
+
+// parseRestartPolicy 解析 spm run 的 --restart，为空时使用 never
+func parseRestartPolicy(s string) (RestartPolicy, error) {
+	switch policy := RestartPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return RestartNever, nil
+	case RestartNever, RestartOnFailure, RestartAlways:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid restart policy %q, supported: never on-failure always", s)
+	}
+}
+
+// RunInfo 由 spm run 注册的临时进程的运行参数，作为 ProcInfo 的一部分返回
+type RunInfo struct {
+	Command  string        `codec:"command" json:"command"`
+	Restart  RestartPolicy `codec:"restart" json:"restart"`
+	Restarts int           `codec:"restarts" json:"restarts"` // 启动后又重新启动的次数
+}
+
+// runInfo 返回临时进程的运行参数，不是临时进程时返回 nil
+func (p *Process) runInfo() *RunInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Options == nil || !p.Options.adhoc {
+		return nil
+	}
+
+	return &RunInfo{
+		Command:  strings.Join(p.Options.cmd, " "),
+		Restart:  p.Options.restart,
+		Restarts: max(p.starts-1, 0),
+	}
+}
+
+// IsAdhoc 判断进程是否由 spm run 注册，这类进程不在 Procfile 中，reload 时保持不变
+func (p *Process) IsAdhoc() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.Options != nil && p.Options.adhoc
+}
+
+// newRunOption 根据 spm run 的请求生成临时进程的配置
+//
+// 参数：
+//
+//	msg: run 请求，CmdLine 为要运行的命令，Env、Restart 和 Tty 可选
+//
+// 返回：
+//
+//	*ProcessOption: 进程配置，命令使用查找到的可执行文件的绝对路径
+//	error: 命令不存在、重启策略无效或运行时目录不可用
+func newRunOption(msg *ActionMsg) (*ProcessOption, error) {
+	if len(msg.CmdLine) == 0 {
+		return nil, fmt.Errorf("command is empty")
+	}
+
+	exePath, err := exec.LookPath(resolveExe(msg.CmdLine[0], msg.WorkDir))
+	if err != nil {
+		return nil, err
+	}
+
+	restart, err := parseRestartPolicy(msg.Restart)
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeDir, err := config.GetRuntimeDir("/var")
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string, len(msg.Env))
+	for k, v := range msg.Env {
+		env[k] = v
+	}
+
+	return &ProcessOption{
+		Root:       msg.WorkDir,
+		PidRoot:    runtimeDir,
+		LogRoot:    runtimeDir,
+		Env:        env,
+		StopSignal: "TERM",
+		NumProcs:   1,
+		Tty:        msg.Tty,
+
+		cmd:     append([]string{exePath}, msg.CmdLine[1:]...),
+		adhoc:   true,
+		restart: restart,
+	}, nil
+}
+
+// validRunName 检查 spm run 指定的进程名
+func validRunName(name string) error {
+	if name != "" && !runNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid process name %q, process name must be consist of 'a-z A-Z 0-9 - _'", name)
+	}
+
+	return nil
+}
+
+// runName 确定临时进程的名称
+//
+// 指定了 name 时，同名的进程必须是没有在运行的临时进程，由调用方用新的命令替换它；
+// 没有指定时使用可执行文件名，已被占用时依次尝试 name-2、name-3 ...
+func (sv *Supervisor) runName(appName, name string, cmd []string) (string, error) {
+	if name != "" {
+		p := sv.procTable.Get(fmt.Sprintf("%s::%s", appName, name))
+		switch {
+		case p == nil:
+			return name, nil
+		case !p.IsAdhoc():
+			return "", fmt.Errorf("process %s is defined in the Procfile", name)
+		case p.IsRunning():
+			return "", fmt.Errorf("process %s is already running, stop or remove it first", name)
+		default:
+			return name, nil
+		}
+	}
+
+	base := runNamePattern.FindString(filepath.Base(cmd[0]))
+	if base == "" {
+		base = "run"
+	}
+
+	name = base
+	for i := 2; sv.procTable.Get(fmt.Sprintf("%s::%s", appName, name)) != nil; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	return name, nil
+}
+
+// Run 注册并启动 spm run 的临时进程
+//
+// 参数：
+//
+//	procOpts: 进程所属项目的配置，Processes 会被替换为该进程，项目不存在时注册整个项目
+//	name: spm run 指定的进程名，为空时使用可执行文件名
+//	opt: newRunOption 生成的进程配置
+//
+// 返回：
+//
+//	*Process: 启动后的进程
+//	error: 进程名已被占用，或者已经有同名的进程在运行
+//
+// 注意事项：
+//
+//	确定名称、替换已经停止的同名临时进程、注册和启动在同一个 sv.mu 临界区中完成，
+//	并发的同名请求不会替换或停止刚刚启动的进程
+func (sv *Supervisor) Run(procOpts *ProcfileOption, name string, opt *ProcessOption) (*Process, error) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	name, err := sv.runName(procOpts.AppName, name, opt.cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// 替换已经停止的同名临时进程，重新开始计算重启次数
+	fullName := fmt.Sprintf("%s::%s", procOpts.AppName, name)
+	if old := sv.procTable.Get(fullName); old != nil {
+		old.cancelRestart()
+		sv.unregister(old)
+	}
+
+	procOpts.Processes = map[string]*ProcessOption{name: opt}
+
+	proj := sv.projectTable.Get(procOpts.AppName)
+	if proj == nil {
+		// 项目不存在时注册整个项目
+		proj = sv.registerApp(procOpts)
+	} else {
+		// 项目已存在时只把该进程加入进程表，不影响项目中的其他进程
+		p := NewProcess(fullName, opt)
+		p.SetPidPath()
+
+		sv.procTable.Add(fullName, p)
+		proj.SetState(name, false)
+	}
+
+	p := sv.procTable.Get(fullName)
+	proj.SetState(name, p.Start())
+
+	return p, nil
+}
+
+// Remove 停止并注销由 spm run 注册的临时进程
+//
+// 参数：
+//
+//	names: 完整进程名列表
+//
+// 返回：
+//
+//	[]*Process: 已移除的进程，状态为移除前停止的结果
+//	error: 有进程不存在或不是临时进程时返回错误，此时不会移除任何进程
+//
+// 注意事项：
+//
+//	Procfile 中的进程需要从 Procfile 中删除后通过 reload 移除
+func (sv *Supervisor) Remove(names []string) ([]*Process, error) {
+	sv.mu.RLock()
+	procs, err := sv.adhocProcs(names)
+	sv.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// 释放 sv.mu 之后再停止，Stop 最多等待 stopTimeout；
+	// 停止期间同名的 spm run 会因为进程仍在运行而失败
+	for _, p := range procs {
+		if p.IsRunning() {
+			p.Stop()
+		} else {
+			p.cancelRestart()
+		}
+	}
+
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	for _, p := range procs {
+		// 停止之后可能已经被同名的 spm run 替换
+		if sv.procTable.Get(p.FullName) == p {
+			sv.unregister(p)
+		}
+
+		p.logger.Infof("Process %s is removed", p.Name)
+	}
+
+	return procs, nil
+}
+
+// adhocProcs 查找要移除的临时进程，有进程不存在或不是临时进程时返回错误，调用方需持有 sv.mu
+func (sv *Supervisor) adhocProcs(names []string) ([]*Process, error) {
+	procs := make([]*Process, 0, len(names))
+	for _, name := range names {
+		p := sv.procTable.Get(name)
+		if p == nil {
+			return nil, fmt.Errorf("%w: %s", errProcessNotFound, name)
+		}
+		if !p.IsAdhoc() {
+			return nil, fmt.Errorf("%s: %w", name, errProcessNotAdhoc)
+		}
+		procs = append(procs, p)
+	}
+
+	return procs, nil
+}
+
+// unregister 从进程表和项目中注销进程，调用方需持有 sv.mu
+func (sv *Supervisor) unregister(p *Process) {
+	_ = sv.procTable.Del(p.FullName)
+
+	appName := strings.Split(p.FullName, "::")[0]
+	if proj := sv.projectTable.Get(appName); proj != nil {
+		proj.Remove(p.Name)
+	}
+}
+
+// restartOnExit 进程自己退出后按重启策略安排重启，连续快速退出时逐次加倍等待时间
+func (p *Process) restartOnExit(exitCode int, uptime time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.Options.restart {
+	case RestartAlways:
+	case RestartOnFailure:
+		if exitCode == 0 {
+			return
+		}
+	default:
+		return
+	}
+
+	switch {
+	case uptime >= restartResetAfter || p.restartDelay == 0:
+		p.restartDelay = restartMinDelay
+	default:
+		p.restartDelay = min(p.restartDelay*2, restartMaxDelay)
+	}
+
+	p.logger.Infof("Restarting process %s in %s (restart %s)", p.Name, p.restartDelay, p.Options.restart)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(p.restartDelay, func() {
+		p.mu.Lock()
+		// 等待期间进程被停止或移除
+		if p.restartTimer != timer {
+			p.mu.Unlock()
+			return
+		}
+		p.restartTimer = nil
+		p.mu.Unlock()
+
+		p.start()
+	})
+	p.restartTimer = timer
+}
+
+// cancelRestart 取消等待中的自动重启
+func (p *Process) cancelRestart() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.restartTimer != nil {
+		p.restartTimer.Stop()
+		p.restartTimer = nil
+	}
+}
@@ -0,0 +1,332 @@
+// Package supervisor 提供进程调度优先级、IO 优先级、OOM 分数和 CPU 亲和性的设置功能
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set/ioprio_get 的参数，见 linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// ioniceClasses ioniceClass 可以使用的名称，与 ionice(1) 一致
+var ioniceClasses = map[string]int{
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// ioniceClassNames 按类别编号排列的名称
+var ioniceClassNames = []string{"none", "realtime", "best-effort", "idle"}
+
+// SchedOption 进程的调度选项，对应 Procfile.options 中进程的同名配置
+//
+// 所有设置都在辅助模式中、exec 目标命令之前完成，进程之后创建的线程和子进程都会继承
+type SchedOption struct {
+	Nice        *int   // 调度优先级，-20（最高）到 19（最低），负值需要 root
+	IoniceClass string // IO 调度类别：realtime、best-effort、idle 或 1-3
+	IoniceLevel *int   // IO 优先级，0（最高）到 7（最低），只对 realtime 和 best-effort 有效
+	OomScoreAdj *int   // OOM 分数调整，-1000 到 1000，降低需要 root
+	CPUAffinity string // 允许运行的 CPU，与 taskset -c 的格式一致，例如 0-3,6
+}
+
+// schedSetting 解析后的调度选项
+type schedSetting struct {
+	nice        *int
+	ioprio      int // ioprio_set 使用的值，0 表示不设置
+	oomScoreAdj *int
+	cpus        []int
+}
+
+// parseSched 解析进程的调度选项
+//
+// 返回：
+//
+//	*schedSetting: 解析后的设置，没有配置任何调度选项时返回 nil
+//	error: 取值超出范围或格式无效
+func parseSched(opt SchedOption) (*schedSetting, error) {
+	if opt.Nice == nil && opt.IoniceClass == "" && opt.IoniceLevel == nil && opt.OomScoreAdj == nil && opt.CPUAffinity == "" {
+		return nil, nil
+	}
+
+	s := &schedSetting{nice: opt.Nice, oomScoreAdj: opt.OomScoreAdj}
+
+	if n := opt.Nice; n != nil && (*n < -20 || *n > 19) {
+		return nil, fmt.Errorf("nice: %d is out of range -20 to 19", *n)
+	}
+
+	if n := opt.OomScoreAdj; n != nil && (*n < -1000 || *n > 1000) {
+		return nil, fmt.Errorf("oomScoreAdj: %d is out of range -1000 to 1000", *n)
+	}
+
+	if opt.IoniceClass != "" || opt.IoniceLevel != nil {
+		ioprio, err := parseIonice(opt.IoniceClass, opt.IoniceLevel)
+		if err != nil {
+			return nil, err
+		}
+		s.ioprio = ioprio
+	}
+
+	if opt.CPUAffinity != "" {
+		cpus, err := parseCPUList(opt.CPUAffinity)
+		if err != nil {
+			return nil, fmt.Errorf("cpuAffinity: %w", err)
+		}
+		s.cpus = cpus
+	}
+
+	return s, nil
+}
+
+// parseIonice 把 IO 调度类别和优先级转换为 ioprio_set 使用的值
+//
+// 只设置 ioniceLevel 时类别为 best-effort；只设置 realtime 或 best-effort 类别时优先级为 4
+func parseIonice(class string, level *int) (int, error) {
+	c := ioniceClasses["best-effort"]
+	if class != "" {
+		var ok bool
+		if c, ok = ioniceClasses[strings.ToLower(class)]; !ok {
+			n, err := strconv.Atoi(class)
+			if err != nil || n < 1 || n > 3 {
+				return 0, fmt.Errorf("ioniceClass: invalid value %q, supported: realtime best-effort idle", class)
+			}
+			c = n
+		}
+	}
+
+	l := 4
+	if level != nil {
+		if *level < 0 || *level > 7 {
+			return 0, fmt.Errorf("ioniceLevel: %d is out of range 0 to 7", *level)
+		}
+		l = *level
+	}
+
+	// idle 类别没有优先级
+	if c == ioniceClasses["idle"] {
+		l = 0
+	}
+
+	return c<<ioprioClassShift | l, nil
+}
+
+// formatIoprio 把 ioprio 的值格式化为 "best-effort:4" 的形式
+func formatIoprio(ioprio int) string {
+	class, level := ioprio>>ioprioClassShift, ioprio&(1<<ioprioClassShift-1)
+	if class < 0 || class >= len(ioniceClassNames) {
+		return strconv.Itoa(ioprio)
+	}
+
+	if class == ioniceClasses["idle"] {
+		return ioniceClassNames[class]
+	}
+
+	return fmt.Sprintf("%s:%d", ioniceClassNames[class], level)
+}
+
+// parseCPUList 解析 "0-3,6" 形式的 CPU 列表，返回排序去重后的 CPU 编号
+func parseCPUList(s string) ([]int, error) {
+	cpus := make([]int, 0)
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isRange {
+			hi = lo
+		}
+
+		start, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		end, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || start < 0 || start > end {
+			return nil, fmt.Errorf("invalid CPU list %q", s)
+		}
+
+		// unix.CPUSet 最多表示 1024 个 CPU
+		if end >= 1024 {
+			return nil, fmt.Errorf("CPU %d is out of range", end)
+		}
+
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	slices.Sort(cpus)
+
+	return slices.Compact(cpus), nil
+}
+
+// formatCPUList 把 CPU 编号格式化为 "0-3,6" 的形式
+func formatCPUList(cpus []int) string {
+	parts := make([]string, 0)
+
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+
+		if i == j {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// String 格式化为 "nice=10 ionice=idle oomScoreAdj=500 cpuAffinity=0-3" 的形式，用于 reload 时比较
+func (s *schedSetting) String() string {
+	if s == nil {
+		return ""
+	}
+
+	parts := make([]string, 0, 4)
+	for _, kv := range s.values() {
+		parts = append(parts, kv[0]+"="+kv[1])
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// values 返回已配置的各项设置，键与 Procfile.options 中的名称一致
+func (s *schedSetting) values() [][2]string {
+	values := make([][2]string, 0, 4)
+	if s.nice != nil {
+		values = append(values, [2]string{"nice", strconv.Itoa(*s.nice)})
+	}
+	if s.ioprio != 0 {
+		values = append(values, [2]string{"ionice", formatIoprio(s.ioprio)})
+	}
+	if s.oomScoreAdj != nil {
+		values = append(values, [2]string{"oomScoreAdj", strconv.Itoa(*s.oomScoreAdj)})
+	}
+	if len(s.cpus) > 0 {
+		values = append(values, [2]string{"cpuAffinity", formatCPUList(s.cpus)})
+	}
+
+	return values
+}
+
+// effectiveSched 返回进程配置的各项调度设置
+//
+// 进程运行时从内核读取实际生效的值，否则返回配置的值；没有配置时返回 nil
+func effectiveSched(pid int, s *schedSetting) map[string]string {
+	if s == nil {
+		return nil
+	}
+
+	result := make(map[string]string, 4)
+	for _, kv := range s.values() {
+		result[kv[0]] = kv[1]
+	}
+
+	if pid <= 0 {
+		return result
+	}
+
+	if s.nice != nil {
+		// getpriority 系统调用返回 20-nice，x/sys 没有做转换
+		if prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid); err == nil {
+			result["nice"] = strconv.Itoa(20 - prio)
+		}
+	}
+
+	if s.ioprio != 0 {
+		if prio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0); errno == 0 {
+			result["ionice"] = formatIoprio(int(prio))
+		}
+	}
+
+	if s.oomScoreAdj != nil {
+		if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid)); err == nil {
+			result["oomScoreAdj"] = strings.TrimSpace(string(data))
+		}
+	}
+
+	if len(s.cpus) > 0 {
+		var set unix.CPUSet
+		if err := unix.SchedGetaffinity(pid, &set); err == nil {
+			cpus := make([]int, 0, set.Count())
+			for cpu := 0; cpu < len(set)*64; cpu++ {
+				if set.IsSet(cpu) {
+					cpus = append(cpus, cpu)
+				}
+			}
+			result["cpuAffinity"] = formatCPUList(cpus)
+		}
+	}
+
+	return result
+}
+
+// schedHelperArgs 生成辅助模式设置调度选项的参数
+func schedHelperArgs(s *schedSetting) []string {
+	if s == nil {
+		return nil
+	}
+
+	args := make([]string, 0, 8)
+	if s.nice != nil {
+		args = append(args, "-nice", strconv.Itoa(*s.nice))
+	}
+	if s.ioprio != 0 {
+		args = append(args, "-ioprio", strconv.Itoa(s.ioprio))
+	}
+	if s.oomScoreAdj != nil {
+		args = append(args, "-oom-score-adj", strconv.Itoa(*s.oomScoreAdj))
+	}
+	if len(s.cpus) > 0 {
+		args = append(args, "-cpus", formatCPUList(s.cpus))
+	}
+
+	return args
+}
+
+// applySched 在辅助模式中设置当前进程的调度选项，需要在切换用户之前调用，root 才能提高优先级
+func applySched(nice, ioprio, oomScoreAdj *int, cpus string) error {
+	if nice != nil {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, *nice); err != nil {
+			return fmt.Errorf("setpriority %d: %w", *nice, err)
+		}
+	}
+
+	if ioprio != nil {
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(*ioprio)); errno != 0 {
+			return fmt.Errorf("ioprio_set %s: %w", formatIoprio(*ioprio), errno)
+		}
+	}
+
+	if oomScoreAdj != nil {
+		if err := os.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(*oomScoreAdj)), 0644); err != nil {
+			return fmt.Errorf("oom_score_adj %d: %w", *oomScoreAdj, err)
+		}
+	}
+
+	if cpus != "" {
+		list, err := parseCPUList(cpus)
+		if err != nil {
+			return err
+		}
+
+		var set unix.CPUSet
+		for _, cpu := range list {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("sched_setaffinity %s: %w", cpus, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package supervisor 提供定时任务的 cron 表达式和固定间隔解析功能
+package supervisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jobSchedule 定时任务的调度规则
+type jobSchedule interface {
+	// Next 返回 t 之后的下一次运行时间，没有下一次时返回零值
+	Next(t time.Time) time.Time
+	// String 返回配置中的原始写法
+	String() string
+}
+
+// cronDescriptors 预定义的 cron 表达式
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dowNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// cronField cron 表达式中单个字段的取值范围
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // 名称对应的值从 min 开始
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	{name: "day of week", min: 0, max: 7, names: dowNames}, // 0 和 7 都表示周日
+}
+
+// parseSchedule 解析进程配置中的 schedule
+//
+// 支持的写法：
+//   - 标准的 5 段 cron 表达式（分 时 日 月 周），例如 "*/5 * * * *"、"30 2 * * mon-fri"
+//   - 预定义的 @yearly、@monthly、@weekly、@daily、@hourly
+//   - 固定间隔："@every 10m" 或直接写 "10m"，从 start 开始计时
+//
+// cron 表达式使用守护进程的本地时区
+func parseSchedule(spec string) (jobSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	if every, ok := strings.CutPrefix(spec, "@every "); ok {
+		return parseInterval(spec, every)
+	}
+
+	if expr, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		s, err := parseCron(expr)
+		if err != nil {
+			return nil, err
+		}
+		s.spec = spec
+		return s, nil
+	}
+
+	if len(strings.Fields(spec)) == 1 {
+		return parseInterval(spec, spec)
+	}
+
+	return parseCron(spec)
+}
+
+// intervalSchedule 固定间隔的调度规则
+type intervalSchedule struct {
+	spec     string
+	interval time.Duration
+}
+
+func parseInterval(spec, value string) (*intervalSchedule, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q, expected a cron expression or an interval such as 10m", spec)
+	}
+
+	if d < time.Second {
+		return nil, fmt.Errorf("schedule interval %s is shorter than 1s", d)
+	}
+
+	return &intervalSchedule{spec: spec, interval: d}, nil
+}
+
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+func (s *intervalSchedule) String() string {
+	return s.spec
+}
+
+// cronSchedule cron 表达式的调度规则，每个字段用位图表示允许的取值
+type cronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool // 日和周字段是否为 *，决定两者按"与"还是"或"匹配
+}
+
+func parseCron(spec string) (*cronSchedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q, expected 5 fields: minute hour day-of-month month day-of-week", spec)
+	}
+
+	bits := make([]uint64, len(cronFields))
+	for i, f := range cronFields {
+		b, err := f.parse(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+		bits[i] = b
+	}
+
+	// 周日可以写成 0 或 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		spec:    spec,
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parse 解析单个字段，支持 *、数字、名称、范围 a-b、步长 /n 和逗号分隔的列表
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rangePart == "*":
+			lo, hi = f.min, f.max
+		default:
+			loStr, hiStr, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			if lo, err = f.value(loStr); err != nil {
+				return 0, err
+			}
+
+			switch {
+			case isRange:
+				if hi, err = f.value(hiStr); err != nil {
+					return 0, err
+				}
+			case hasStep:
+				// "5/15" 表示从 5 开始到最大值
+				hi = f.max
+			default:
+				hi = lo
+			}
+		}
+
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q in %s", rangePart, f.name)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// value 解析单个数字或名称，并检查取值范围
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s", s, f.name)
+	}
+
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s %d is out of range %d-%d", f.name, n, f.min, f.max)
+	}
+
+	return n, nil
+}
+
+// Next 从 t 的下一分钟开始逐级查找匹配的月、日、时、分，最多向后查找 5 年
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+// dayMatches 与 Vixie cron 一致：日和周都有限制时满足其一即可，否则两者都要满足
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+func (s *cronSchedule) String() string {
+	return s.spec
+}
@@ -0,0 +1,207 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "every minute",
+			spec: "* * * * *",
+			from: time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC),
+			want: date(2024, 1, 1, 10, 8),
+		},
+		{
+			name: "strictly after a matching minute",
+			spec: "*/15 * * * *",
+			from: date(2024, 1, 1, 10, 15),
+			want: date(2024, 1, 1, 10, 30),
+		},
+		{
+			name: "step",
+			spec: "*/15 * * * *",
+			from: date(2024, 1, 1, 10, 7),
+			want: date(2024, 1, 1, 10, 15),
+		},
+		{
+			name: "step from start value",
+			spec: "5/20 * * * *",
+			from: date(2024, 1, 1, 10, 26),
+			want: date(2024, 1, 1, 10, 45),
+		},
+		{
+			name: "range with step",
+			spec: "0 9-17/4 * * *",
+			from: date(2024, 1, 1, 10, 0),
+			want: date(2024, 1, 1, 13, 0),
+		},
+		{
+			name: "range rolls over to next day",
+			spec: "0 9-17/4 * * *",
+			from: date(2024, 1, 1, 17, 0),
+			want: date(2024, 1, 2, 9, 0),
+		},
+		{
+			name: "list",
+			spec: "0 8,20 * * *",
+			from: date(2024, 1, 1, 9, 0),
+			want: date(2024, 1, 1, 20, 0),
+		},
+		{
+			name: "weekday names",
+			spec: "30 2 * * mon-fri",
+			from: date(2024, 1, 6, 3, 0), // 周六
+			want: date(2024, 1, 8, 2, 30),
+		},
+		{
+			name: "month names",
+			spec: "0 0 1 jan,JUL *",
+			from: date(2024, 2, 10, 0, 0),
+			want: date(2024, 7, 1, 0, 0),
+		},
+		{
+			name: "day of month only",
+			spec: "0 0 1 * *",
+			from: date(2024, 1, 15, 0, 0),
+			want: date(2024, 2, 1, 0, 0),
+		},
+		{
+			name: "day of month or day of week, weekday first",
+			spec: "0 0 13 * fri",
+			from: date(2024, 1, 1, 0, 0),
+			want: date(2024, 1, 5, 0, 0),
+		},
+		{
+			name: "day of month or day of week, month day first",
+			spec: "0 0 13 * fri",
+			from: date(2024, 1, 12, 0, 0),
+			want: date(2024, 1, 13, 0, 0),
+		},
+		{
+			name: "day of week with starred day of month",
+			spec: "0 0 * * 3",
+			from: date(2024, 1, 1, 0, 0),
+			want: date(2024, 1, 3, 0, 0),
+		},
+		{
+			name: "day 7 is sunday",
+			spec: "0 12 * * 7",
+			from: date(2024, 1, 1, 0, 0),
+			want: date(2024, 1, 7, 12, 0),
+		},
+		{
+			name: "range ending in 7 includes sunday",
+			spec: "0 12 * * 5-7",
+			from: date(2024, 1, 6, 13, 0),
+			want: date(2024, 1, 7, 12, 0),
+		},
+		{
+			name: "year wrap",
+			spec: "0 0 1 1 *",
+			from: date(2024, 12, 31, 23, 59),
+			want: date(2025, 1, 1, 0, 0),
+		},
+		{
+			name: "last minute of the year",
+			spec: "59 23 31 12 *",
+			from: date(2024, 12, 31, 23, 59),
+			want: date(2025, 12, 31, 23, 59),
+		},
+		{
+			name: "leap day",
+			spec: "0 0 29 2 *",
+			from: date(2025, 3, 1, 0, 0),
+			want: date(2028, 2, 29, 0, 0),
+		},
+		{
+			name: "impossible date",
+			spec: "0 0 31 2 *",
+			from: date(2024, 1, 1, 0, 0),
+			want: time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCron(%q) error = %v", tt.spec, err)
+			}
+
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"a * * * *",
+	}
+
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			if _, err := parseCron(spec); err == nil {
+				t.Errorf("parseCron(%q) expected error", spec)
+			}
+		})
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 7, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "@hourly", want: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{spec: "@daily", want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{spec: "@weekly", want: time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{spec: "@every 10m", want: from.Add(10 * time.Minute)},
+		{spec: "90s", want: from.Add(90 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := parseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("parseSchedule(%q) error = %v", tt.spec, err)
+			}
+			if s.String() != tt.spec {
+				t.Errorf("String() = %q, want %q", s.String(), tt.spec)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "500ms", "@every soon", "tomorrow"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("parseSchedule(%q) expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package supervisor 提供按内存和 CPU 占用自动重启进程的看门狗功能
+package supervisor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// watchdogInterval 看门狗的采样间隔
+const watchdogInterval = 5 * time.Second
+
+// defaultWatchdogFor 超过阈值多长时间后触发，Procfile.options 中没有设置 for 时使用
+const defaultWatchdogFor = time.Minute
+
+// RestartMode 看门狗触发后对进程的处理方式
+type RestartMode string
+
+const (
+	RestartModeRestart RestartMode = "restart" // 重启进程（默认）
+	RestartModeStop    RestartMode = "stop"    // 停止进程，不再启动
+	RestartModeNone    RestartMode = "none"    // 只记录日志和事件
+)
+
+// WatchdogOption 进程的看门狗配置，对应 Procfile.options 中进程的 watchdog
+//
+// MaxMemory 和 MaxCPU 至少设置一项，任意一项持续超过阈值 For 时间后触发
+type WatchdogOption struct {
+	MaxMemory   string // 常驻内存上限，例如 512M
+	MaxCPU      string // CPU 占用上限，单核百分比，例如 90%、150%
+	For         string // 持续超过阈值的时间，例如 30s，默认 1m
+	RestartMode string // 触发后的处理方式：restart、stop、none
+}
+
+// watchdogSetting 解析后的看门狗配置
+type watchdogSetting struct {
+	maxRSS int64         // 字节，0 表示不检查
+	maxCPU float64       // CPU 核数，0 表示不检查
+	period time.Duration // 持续时间
+	mode   RestartMode
+}
+
+// String 格式化为 "maxMemory=512M maxCpu=90% for=30s restartMode=restart" 的形式，用于 reload 时比较
+func (w *watchdogSetting) String() string {
+	if w == nil {
+		return ""
+	}
+
+	parts := make([]string, 0, 4)
+	if w.maxRSS > 0 {
+		parts = append(parts, "maxMemory="+strconv.FormatInt(w.maxRSS, 10))
+	}
+	if w.maxCPU > 0 {
+		parts = append(parts, "maxCpu="+strconv.FormatFloat(w.maxCPU*100, 'f', -1, 64)+"%")
+	}
+	parts = append(parts, "for="+w.period.String(), "restartMode="+string(w.mode))
+
+	return strings.Join(parts, " ")
+}
+
+// parseWatchdog 解析进程的看门狗配置
+//
+// 返回：
+//
+//	*watchdogSetting: 解析后的配置，opt 为 nil 时返回 nil
+//	error: 阈值、时间或处理方式无效
+func parseWatchdog(opt *WatchdogOption) (*watchdogSetting, error) {
+	if opt == nil {
+		return nil, nil
+	}
+
+	w := &watchdogSetting{period: defaultWatchdogFor, mode: RestartModeRestart}
+
+	if v := strings.TrimSpace(opt.MaxMemory); v != "" {
+		n, err := parseSize(v)
+		if err != nil || n == 0 || n > 1<<62 {
+			return nil, fmt.Errorf("maxMemory: invalid value %q", v)
+		}
+		w.maxRSS = int64(n)
+	}
+
+	if v := strings.TrimSpace(opt.MaxCPU); v != "" {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil || percent <= 0 {
+			return nil, fmt.Errorf("maxCpu: invalid value %q, expected a percentage of one CPU such as 90%%", v)
+		}
+		w.maxCPU = percent / 100
+	}
+
+	if w.maxRSS == 0 && w.maxCPU == 0 {
+		return nil, fmt.Errorf("at least one of maxMemory and maxCpu is required")
+	}
+
+	if v := strings.TrimSpace(opt.For); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("for: %w", err)
+		}
+		if d < watchdogInterval {
+			return nil, fmt.Errorf("for: %s is shorter than the sampling interval %s", d, watchdogInterval)
+		}
+		w.period = d
+	}
+
+	if v := strings.ToLower(strings.TrimSpace(opt.RestartMode)); v != "" {
+		switch mode := RestartMode(v); mode {
+		case RestartModeRestart, RestartModeStop, RestartModeNone:
+			w.mode = mode
+		default:
+			return nil, fmt.Errorf("restartMode: invalid value %q, supported: restart stop none", opt.RestartMode)
+		}
+	}
+
+	return w, nil
+}
+
+// watchdogState 单个进程的采样状态
+type watchdogState struct {
+	pid     int       // 采样的进程 PID，进程重启后重新开始采样
+	cpu     float64   // 上一次采样的 CPU 时间（秒）
+	sampled time.Time // 上一次采样的时间
+	since   time.Time // 开始超过阈值的时间，未超过时为零值
+}
+
+// runWatchdog 定期采样所有配置了看门狗的运行中进程，超过阈值持续指定时间后按 restartMode 处理
+//
+// 启用 cgroup 时 Process.Usage 按整个叶子 cgroup 统计，子进程的占用也计入阈值
+//
+// 注意事项：
+//
+//	在守护进程中以 goroutine 运行，收到 stop 时退出
+func (sv *Supervisor) runWatchdog(stop <-chan struct{}) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	states := make(map[string]*watchdogState)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			seen := make(map[string]bool)
+			for name, p := range sv.procTable.Iter() {
+				if sv.checkWatchdog(p, states, now) {
+					seen[name] = true
+				}
+			}
+
+			// 清理已删除、已停止或取消看门狗的进程的状态
+			for name := range states {
+				if !seen[name] {
+					delete(states, name)
+				}
+			}
+		}
+	}
+}
+
+// checkWatchdog 采样单个进程并在需要时触发，进程仍需要继续采样时返回 true
+func (sv *Supervisor) checkWatchdog(p *Process, states map[string]*watchdogState, now time.Time) bool {
+	p.mu.Lock()
+	w := p.Options.watchdog
+	p.mu.Unlock()
+
+	if w == nil {
+		return false
+	}
+
+	usage := p.Usage()
+	if usage == nil {
+		return false
+	}
+
+	st := states[p.FullName]
+	if st == nil || st.pid != p.Pid {
+		// 第一次采样只记录 CPU 时间，下一次采样才能计算占用率
+		states[p.FullName] = &watchdogState{pid: p.Pid, cpu: usage.CPUSeconds, sampled: now}
+		return true
+	}
+
+	cpu := (usage.CPUSeconds - st.cpu) / now.Sub(st.sampled).Seconds()
+	st.cpu, st.sampled = usage.CPUSeconds, now
+
+	var reasons []string
+	if w.maxRSS > 0 && usage.RSSBytes > w.maxRSS {
+		reasons = append(reasons, fmt.Sprintf("memory %d bytes is above %d", usage.RSSBytes, w.maxRSS))
+	}
+	if w.maxCPU > 0 && cpu > w.maxCPU {
+		reasons = append(reasons, fmt.Sprintf("cpu %.0f%% is above %.0f%%", cpu*100, w.maxCPU*100))
+	}
+
+	if len(reasons) == 0 {
+		st.since = time.Time{}
+		return true
+	}
+
+	if st.since.IsZero() {
+		st.since = now
+	}
+
+	if now.Sub(st.since) < w.period {
+		return true
+	}
+
+	message := fmt.Sprintf("watchdog: %s for %s", strings.Join(reasons, ", "), w.period)
+	p.logger.Warnf("Process %s %s, restartMode=%s", p.Name, message, w.mode)
+	publishEvent(p, EventWatchdog, 0, message)
+
+	switch w.mode {
+	case RestartModeRestart:
+		sv.Restart(p.FullName)
+	case RestartModeStop:
+		sv.Stop(p.FullName)
+	case RestartModeNone:
+		// 只记录，持续超过阈值时每个周期提醒一次
+		st.since = now
+		return true
+	}
+
+	delete(states, p.FullName)
+
+	return false
+}
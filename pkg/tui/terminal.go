@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminal 终端的原始模式和窗口大小
+type terminal struct {
+	in  *os.File
+	out *os.File
+	old *unix.Termios
+}
+
+// openTerminal 检查标准输入输出都是终端，并把标准输入切换到原始模式
+//
+// 原始模式下按键不回显、不按行缓冲，Ctrl-C 作为普通按键读取；
+// 保留输出处理，"\n" 仍然换行到行首
+func openTerminal(in, out *os.File) (*terminal, error) {
+	if _, err := unix.IoctlGetTermios(int(out.Fd()), unix.TCGETS); err != nil {
+		return nil, errNotTerminal
+	}
+
+	old, err := unix.IoctlGetTermios(int(in.Fd()), unix.TCGETS)
+	if err != nil {
+		return nil, errNotTerminal
+	}
+
+	raw := *old
+	raw.Iflag &^= unix.BRKINT | unix.ICRNL | unix.INPCK | unix.ISTRIP | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(int(in.Fd()), unix.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+
+	return &terminal{in: in, out: out, old: old}, nil
+}
+
+// restore 恢复终端原来的模式
+func (t *terminal) restore() {
+	_ = unix.IoctlSetTermios(int(t.in.Fd()), unix.TCSETS, t.old)
+}
+
+// size 返回终端的列数和行数，无法获取时返回 80x24
+func (t *terminal) size() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(t.out.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+
+	return int(ws.Col), int(ws.Row)
+}
+
+// readKeys 持续读取按键并发送到 keys，读取失败时关闭 keys
+func (t *terminal) readKeys(keys chan<- string) {
+	defer close(keys)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := t.in.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// escKeys 方向键等按键的转义序列
+var escKeys = map[string]string{
+	"\x1b[A":  "up",
+	"\x1b[B":  "down",
+	"\x1b[C":  "right",
+	"\x1b[D":  "left",
+	"\x1b[H":  "home",
+	"\x1b[F":  "end",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdn",
+	"\x1bOA":  "up",
+	"\x1bOB":  "down",
+	"\x1bOH":  "home",
+	"\x1bOF":  "end",
+}
+
+// parseKeys 把一次读取到的字节解析为按键名称，普通字符返回字符本身
+func parseKeys(b []byte) []string {
+	keys := make([]string, 0, len(b))
+
+	for i := 0; i < len(b); {
+		if b[i] == 0x1b {
+			matched := false
+			for seq, name := range escKeys {
+				if len(b)-i >= len(seq) && string(b[i:i+len(seq)]) == seq {
+					keys = append(keys, name)
+					i += len(seq)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				keys = append(keys, "esc")
+				i++
+			}
+			continue
+		}
+
+		switch c := b[i]; c {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		default:
+			if c >= 0x20 && c < 0x7f {
+				keys = append(keys, string(c))
+			}
+		}
+		i++
+	}
+
+	return keys
+}
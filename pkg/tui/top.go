@@ -0,0 +1,674 @@
+// Package tui 提供 spm top 的交互式终端界面
+//
+// 界面通过 client.Client 与守护进程通信，因此既可以查看本机的守护进程，
+// 也可以通过 spm.yml 中的 client.host 查看远程守护进程
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"spm/pkg/client"
+	"spm/pkg/supervisor"
+)
+
+var errNotTerminal = errors.New("spm top requires an interactive terminal")
+
+const (
+	logTail        = 200             // 切换进程时先读取的历史日志行数
+	logKeep        = 500             // 日志窗格保留的最多行数
+	messageShown   = 5 * time.Second // 状态栏消息的显示时间
+	refreshTimeout = 5 * time.Second // 单次刷新的超时时间，超时后保留上一次的结果
+)
+
+// Options spm top 的配置
+type Options struct {
+	Interval time.Duration // 刷新间隔
+}
+
+// procRow 表格中的一个进程
+type procRow struct {
+	project string
+	name    string
+	info    *supervisor.ProcInfo
+	cpu     float64 // CPU 占用百分比，小于 0 表示还没有两次采样
+}
+
+// cpuSample 计算 CPU 占用所需的上一次采样
+type cpuSample struct {
+	pid int
+	cpu float64
+	at  time.Time
+}
+
+// actionResult 后台执行的控制操作的结果
+type actionResult struct {
+	message string
+	err     error
+}
+
+type top struct {
+	c    *client.Client
+	term *terminal
+	opts Options
+
+	rows     []*procRow
+	selected string // 选中进程的完整进程名
+	offset   int    // 表格滚动的起始行
+	samples  map[string]cpuSample
+	err      error
+	updated  time.Time
+
+	message   string
+	messageAt time.Time
+	prompt    *string // 不为 nil 时正在输入要发送的信号
+
+	showLogs bool
+	logs     *logPane
+	results  chan actionResult
+}
+
+// Top 运行交互式界面，按 q 或 Ctrl-C 退出
+//
+// 参数：
+//
+//	ctx: 取消时退出界面
+//	c: 连接守护进程的客户端，启动、停止等操作可能需要等待进程退出，建议不设置超时
+//	opts: 刷新间隔等配置
+//
+// 返回：
+//
+//	error: 标准输入输出不是终端或无法切换终端模式时返回错误
+func Top(ctx context.Context, c *client.Client, opts Options) error {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+
+	term, err := openTerminal(os.Stdin, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer term.restore()
+
+	// 使用备用屏幕并隐藏光标，退出时恢复
+	_, _ = fmt.Fprint(os.Stdout, "\033[?1049h\033[?25l")
+	defer func() {
+		_, _ = fmt.Fprint(os.Stdout, "\033[?25h\033[?1049l")
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t := &top{
+		c:        c,
+		term:     term,
+		opts:     opts,
+		samples:  make(map[string]cpuSample),
+		showLogs: true,
+		logs:     newLogPane(c),
+		results:  make(chan actionResult, 4),
+	}
+	defer t.logs.stop()
+
+	keys := make(chan string, 16)
+	go term.readKeys(keys)
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	t.refresh(ctx)
+	t.draw()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			t.refresh(ctx)
+		case <-winch:
+		case <-t.logs.changed:
+		case r := <-t.results:
+			if r.err != nil {
+				t.setMessage("ERROR: " + r.err.Error())
+			} else {
+				t.setMessage(r.message)
+			}
+			t.refresh(ctx)
+		case k, ok := <-keys:
+			if !ok || t.handleKey(ctx, k) {
+				return nil
+			}
+		}
+
+		t.draw()
+	}
+}
+
+// refresh 查询所有进程的状态和资源占用
+func (t *top) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	res, err := t.c.StatusVerbose(ctx)
+	if err != nil && !client.IsNotFound(err) {
+		t.err = err
+		return
+	}
+	t.err = nil
+	t.updated = time.Now()
+
+	rows := make([]*procRow, 0)
+	seen := make(map[string]bool)
+	if res != nil {
+		for _, info := range res.Processes {
+			if info.IsNotFound() {
+				continue
+			}
+
+			project, name, _ := strings.Cut(info.Name, "::")
+			row := &procRow{project: project, name: name, info: info, cpu: -1}
+			rows = append(rows, row)
+			seen[info.Name] = true
+
+			if info.Detail == nil || info.Detail.Usage == nil || info.Pid <= 0 {
+				delete(t.samples, info.Name)
+				continue
+			}
+
+			now := time.Now()
+			cur := cpuSample{pid: info.Pid, cpu: info.Detail.Usage.CPUSeconds, at: now}
+			if prev, ok := t.samples[info.Name]; ok && prev.pid == cur.pid && now.After(prev.at) {
+				row.cpu = max((cur.cpu-prev.cpu)/now.Sub(prev.at).Seconds()*100, 0)
+			}
+			t.samples[info.Name] = cur
+		}
+	}
+
+	for name := range t.samples {
+		if !seen[name] {
+			delete(t.samples, name)
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].project != rows[j].project {
+			return rows[i].project < rows[j].project
+		}
+		return rows[i].name < rows[j].name
+	})
+	t.rows = rows
+
+	if t.selectedIndex() < 0 && len(rows) > 0 {
+		t.selected = rows[0].info.Name
+	}
+	if t.showLogs {
+		t.logs.follow(t.selected)
+	}
+}
+
+// selectedIndex 返回选中进程在表格中的位置，没有选中时返回 -1
+func (t *top) selectedIndex() int {
+	for i, row := range t.rows {
+		if row.info.Name == t.selected {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// handleKey 处理一个按键，返回 true 时退出
+func (t *top) handleKey(ctx context.Context, key string) bool {
+	if t.prompt != nil {
+		switch key {
+		case "enter":
+			sig := strings.TrimSpace(*t.prompt)
+			t.prompt = nil
+			if sig != "" && t.selected != "" {
+				t.run(ctx, fmt.Sprintf("Sent %s to %s", strings.ToUpper(sig), t.selected), func() error {
+					_, err := t.c.Signal(ctx, sig, t.selected)
+					return err
+				})
+			}
+		case "esc", "ctrl-c":
+			t.prompt = nil
+		case "backspace":
+			if n := len(*t.prompt); n > 0 {
+				*t.prompt = (*t.prompt)[:n-1]
+			}
+		default:
+			if len(key) == 1 {
+				*t.prompt += key
+			}
+		}
+		return false
+	}
+
+	switch key {
+	case "q", "ctrl-c":
+		return true
+	case "up", "k":
+		t.move(-1)
+	case "down", "j":
+		t.move(1)
+	case "pgup":
+		t.move(-10)
+	case "pgdn":
+		t.move(10)
+	case "home", "g":
+		t.move(-len(t.rows))
+	case "end", "G":
+		t.move(len(t.rows))
+	case "s":
+		t.control(ctx, "Started", t.c.Start)
+	case "x":
+		t.control(ctx, "Stopped", t.c.Stop)
+	case "r":
+		t.control(ctx, "Restarted", t.c.Restart)
+	case "!":
+		if t.selected != "" {
+			sig := ""
+			t.prompt = &sig
+		}
+	case "l":
+		t.showLogs = !t.showLogs
+		if t.showLogs {
+			t.logs.follow(t.selected)
+		} else {
+			t.logs.stop()
+		}
+	}
+
+	return false
+}
+
+// move 移动选中的进程
+func (t *top) move(delta int) {
+	if len(t.rows) == 0 {
+		return
+	}
+
+	i := min(max(t.selectedIndex()+delta, 0), len(t.rows)-1)
+	t.selected = t.rows[i].info.Name
+	if t.showLogs {
+		t.logs.follow(t.selected)
+	}
+}
+
+// control 在后台对选中的进程执行启动、停止或重启，停止进程可能需要等待较长时间
+func (t *top) control(ctx context.Context, done string, fn func(context.Context, ...string) (*client.Response, error)) {
+	if t.selected == "" {
+		return
+	}
+
+	name := t.selected
+	t.run(ctx, fmt.Sprintf("%s %s", done, name), func() error {
+		_, err := fn(ctx, name)
+		return err
+	})
+}
+
+// run 在后台执行操作，结果显示在底部的状态栏
+func (t *top) run(ctx context.Context, message string, fn func() error) {
+	t.setMessage("Working...")
+
+	go func() {
+		err := fn()
+		select {
+		case t.results <- actionResult{message: message, err: err}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (t *top) setMessage(message string) {
+	t.message = message
+	t.messageAt = time.Now()
+}
+
+// draw 重绘整个屏幕
+func (t *top) draw() {
+	width, height := t.term.size()
+	lines := make([]string, 0, height)
+
+	// 标题栏
+	running := 0
+	projects := make(map[string]bool)
+	for _, row := range t.rows {
+		projects[row.project] = true
+		if row.info.Status == "Running" {
+			running++
+		}
+	}
+	title := fmt.Sprintf(" spm top  %s  %d projects  %d processes  %d running",
+		time.Now().Format(time.TimeOnly), len(projects), len(t.rows), running)
+	if t.err != nil {
+		title += "  ERROR: " + t.err.Error()
+	}
+	lines = append(lines, style("7", fit(title, width)))
+
+	nameWidth := 12
+	for _, row := range t.rows {
+		nameWidth = max(nameWidth, len(row.name)+2)
+	}
+	nameWidth = min(nameWidth, max(width/3, 12))
+
+	header := fmt.Sprintf("%-*s %-10s %7s %9s %6s %8s %8s", nameWidth, "NAME", "STATE", "PID", "UPTIME", "CPU%", "MEM", "RESTARTS")
+	lines = append(lines, style("1", fit(header, width)))
+
+	// 表格和日志窗格分配除标题、表头和状态栏以外的空间
+	avail := max(height-3, 1)
+	tableLines := t.tableLines()
+	tableHeight := min(len(tableLines), avail)
+	if t.showLogs {
+		tableHeight = min(len(tableLines), max(avail/2, avail-logHeightMax(avail)))
+	}
+	tableHeight = max(tableHeight, min(1, avail))
+
+	// 保证选中的进程在可见范围内
+	sel := -1
+	for i, l := range tableLines {
+		if l.name == t.selected {
+			sel = i
+		}
+	}
+	if sel >= 0 {
+		if sel < t.offset {
+			t.offset = sel
+		}
+		if sel >= t.offset+tableHeight {
+			t.offset = sel - tableHeight + 1
+		}
+	}
+	t.offset = min(t.offset, max(len(tableLines)-tableHeight, 0))
+
+	for i := t.offset; i < len(tableLines) && i < t.offset+tableHeight; i++ {
+		l := tableLines[i]
+		if l.row == nil {
+			lines = append(lines, style("1;36", fit(l.text, width)))
+			continue
+		}
+
+		text := fit(fmt.Sprintf("%-*s %s", nameWidth, truncate("  "+l.row.name, nameWidth), l.text), width)
+		switch {
+		case l.name == t.selected:
+			lines = append(lines, style("7", text))
+		case l.row.info.Status == "Running":
+			lines = append(lines, text)
+		default:
+			lines = append(lines, style("2", text))
+		}
+	}
+	if len(t.rows) == 0 && t.err == nil {
+		lines = append(lines, fit("  No processes. Start a project with spm start.", width))
+	}
+
+	// 日志窗格
+	if t.showLogs {
+		logHeight := height - 1 - len(lines)
+		if logHeight > 1 {
+			name, logLines := t.logs.snapshot()
+			lines = append(lines, style("1", fit(fmt.Sprintf("── logs: %s ", name)+strings.Repeat("─", width), width)))
+			logLines = logLines[max(len(logLines)-(logHeight-1), 0):]
+			for _, l := range logLines {
+				if strings.HasPrefix(l, "!") {
+					lines = append(lines, style("31", fit(l, width)))
+				} else {
+					lines = append(lines, fit(l, width))
+				}
+			}
+		}
+	}
+
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = lines[:height-1]
+
+	// 状态栏
+	var footer string
+	switch {
+	case t.prompt != nil:
+		footer = style("1", fit(fmt.Sprintf(" Signal to send to %s: %s_", t.selected, *t.prompt), width))
+	case t.message != "" && time.Since(t.messageAt) < messageShown:
+		footer = style("7", fit(" "+t.message, width))
+	default:
+		footer = style("7", fit(" j/k move  s start  x stop  r restart  ! signal  l logs  q quit", width))
+	}
+	lines = append(lines, footer)
+
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, l := range lines {
+		b.WriteString(l)
+		b.WriteString("\033[K")
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\033[J")
+	_, _ = os.Stdout.WriteString(b.String())
+}
+
+// logHeightMax 日志窗格最多占用的行数
+func logHeightMax(avail int) int {
+	return avail * 2 / 3
+}
+
+// tableLine 表格中的一行，项目标题行的 row 为 nil
+type tableLine struct {
+	name string
+	text string
+	row  *procRow
+}
+
+// tableLines 按项目分组生成表格的各行
+func (t *top) tableLines() []tableLine {
+	lines := make([]tableLine, 0, len(t.rows)*2)
+
+	project := ""
+	for _, row := range t.rows {
+		if row.project != project || len(lines) == 0 {
+			project = row.project
+			lines = append(lines, tableLine{text: project})
+		}
+
+		info := row.info
+		pid, uptime, cpu, mem := "-", "-", "-", "-"
+		if info.Status == "Running" && info.Pid > 0 {
+			pid = fmt.Sprint(info.Pid)
+			if info.StartAt > 0 {
+				uptime = formatUptime(time.Since(time.UnixMilli(info.StartAt)))
+			}
+		}
+		if row.cpu >= 0 {
+			cpu = fmt.Sprintf("%.1f", row.cpu)
+		}
+		if info.Detail != nil && info.Detail.Usage != nil {
+			mem = formatBytes(info.Detail.Usage.RSSBytes)
+		}
+
+		lines = append(lines, tableLine{
+			name: info.Name,
+			row:  row,
+			text: fmt.Sprintf("%-10s %7s %9s %6s %8s %8d", info.Status, pid, uptime, cpu, mem, info.Restarts),
+		})
+	}
+
+	return lines
+}
+
+// formatUptime 把运行时长格式化为 "3d4h"、"2h05m"、"4m12s" 的形式
+func formatUptime(d time.Duration) string {
+	d = d.Round(time.Second)
+
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+// formatBytes 把字节数格式化为 "512K"、"12.3M"、"1.2G" 的形式
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%dK", n>>10)
+	}
+}
+
+// style 为一行文本加上 SGR 样式
+func style(sgr, s string) string {
+	return "\033[" + sgr + "m" + s + "\033[0m"
+}
+
+// fit 把文本截断或补齐到 width 列
+func fit(s string, width int) string {
+	s = truncate(s, width)
+	if n := len([]rune(s)); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+
+	return s
+}
+
+// truncate 按字符数截断文本
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+
+	return string(r[:max(width, 0)])
+}
+
+// logPane 跟踪选中进程的日志
+type logPane struct {
+	c       *client.Client
+	changed chan struct{}
+
+	mu     sync.Mutex
+	name   string
+	lines  []string
+	cancel context.CancelFunc
+}
+
+func newLogPane(c *client.Client) *logPane {
+	return &logPane{c: c, changed: make(chan struct{}, 1)}
+}
+
+// follow 切换到跟踪 name 的日志，已经在跟踪时不做任何事
+func (lp *logPane) follow(name string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	if name == lp.name && lp.cancel != nil {
+		return
+	}
+
+	if lp.cancel != nil {
+		lp.cancel()
+		lp.cancel = nil
+	}
+
+	lp.name = name
+	lp.lines = nil
+	if name == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lp.cancel = cancel
+
+	go func() {
+		err := lp.c.Logs(ctx, name, logTail, func(line *supervisor.LogLine) error {
+			text := sanitize(line.Line)
+			if line.Stream == "stderr" {
+				text = "! " + text
+			} else {
+				text = "  " + text
+			}
+			lp.append(ctx, text)
+			return nil
+		})
+
+		if err != nil && ctx.Err() == nil {
+			lp.append(ctx, fmt.Sprintf("! log stream ended: %v", err))
+		}
+	}()
+}
+
+// append 追加一行日志，跟踪已经切换到其他进程时丢弃
+func (lp *logPane) append(ctx context.Context, text string) {
+	lp.mu.Lock()
+	if ctx.Err() != nil {
+		lp.mu.Unlock()
+		return
+	}
+	lp.lines = append(lp.lines, text)
+	if len(lp.lines) > logKeep {
+		lp.lines = lp.lines[len(lp.lines)-logKeep:]
+	}
+	lp.mu.Unlock()
+
+	select {
+	case lp.changed <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot 返回正在跟踪的进程名和日志行的副本
+func (lp *logPane) snapshot() (string, []string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	return lp.name, append([]string(nil), lp.lines...)
+}
+
+// stop 停止跟踪日志
+func (lp *logPane) stop() {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	if lp.cancel != nil {
+		lp.cancel()
+		lp.cancel = nil
+	}
+	lp.name = ""
+	lp.lines = nil
+}
+
+// sanitize 去掉日志中会破坏界面的控制字符，制表符替换为空格
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case r < 0x20 || r == 0x7f:
+			return -1
+		default:
+			return r
+		}
+	}, s)
+}
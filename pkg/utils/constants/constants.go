@@ -9,6 +9,7 @@ import (
 const (
 	DefaultLogLevel   = "debug"
 	DefaultDaemonName = "spm"
+	DefaultRemotePort = "7000"
 )
 
 var SpmHome = getHome()
@@ -16,6 +17,7 @@ var SpmHome = getHome()
 var DaemonLogFilePath = getDaemonPath("log")
 var DaemonPidFilePath = getDaemonPath("pid")
 var DaemonSockFilePath = getDaemonPath("sock")
+var DaemonHTTPSockFilePath = getDaemonPath("http.sock")
 
 func getHome() string {
 	return fmt.Sprintf("%s/.spm", os.Getenv("HOME"))
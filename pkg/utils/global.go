@@ -20,7 +20,11 @@ var RuntimeModuleName = RuntimeModuleInfo[len(RuntimeModuleInfo)-1]
 
 var SupervisorPid = os.Getpid()
 
-var FinishChan = make(chan struct{}, 1)
+// BinaryVersion 当前 spm 可执行文件的版本，由 cmd.Execute 设置
+var BinaryVersion string
+
+// FinishChan 关闭时通知所有控制服务器停止监听
+var FinishChan = make(chan struct{})
 var StopChan = make(chan os.Signal, 1)
 
 func WriteDaemonPid(pid int) error {
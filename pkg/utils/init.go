@@ -9,7 +9,9 @@ import (
 )
 
 func setup() {
-	config.SetConfig(GlobalConfigFile)
+	if err := config.SetConfig(GlobalConfigFile); err != nil {
+		log.Fatal(err)
+	}
 
 	_, err := os.Stat(constants.SpmHome)
 	if err != nil {